@@ -0,0 +1,199 @@
+package slirc
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	ircc "github.com/fluffle/goirc/client"
+)
+
+// searchLimit is the number of matches returned by the search command
+const searchLimit = 5
+
+// ArchivedMessage is a message that has been relayed by the bridge
+type ArchivedMessage struct {
+	Time    time.Time `json:"time"`
+	Network string    `json:"network"` // "irc" or "slack"
+	Channel string    `json:"channel"`
+	Nick    string    `json:"nick"`
+	UserID  string    `json:"user_id,omitempty"`
+	Account string    `json:"account,omitempty"` // irc services account, if known
+	MsgID   string    `json:"msgid,omitempty"`   // irc msgid, if known
+	Text    string    `json:"text"`
+}
+
+func (am *ArchivedMessage) String() string {
+	return fmt.Sprintf("%s [%s] <%s> %s", am.Time.Format("2006-01-02 15:04"), am.Network, am.Nick, am.Text)
+}
+
+// Archive stores relayed messages and searches them
+type Archive interface {
+	Record(msg *ArchivedMessage) error
+	// Search returns at most limit messages containing term, newest first
+	Search(term string, limit int) ([]*ArchivedMessage, error)
+}
+
+// jsonlArchive writes one JSON object per line into a file per day
+type jsonlArchive struct {
+	dir string
+
+	mu  sync.Mutex
+	day string
+	f   *os.File
+}
+
+const archiveFilePrefix = "slirc-"
+const archiveFileSuffix = ".jsonl"
+
+// NewJSONLArchive returns an Archive writing append-only JSONL files,
+// rotated daily, into dir. dir is created if it does not exist.
+func NewJSONLArchive(dir string) (Archive, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("Failed to create archive directory: %v", err)
+	}
+	return &jsonlArchive{dir: dir}, nil
+}
+
+func (ja *jsonlArchive) Record(msg *ArchivedMessage) error {
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	ja.mu.Lock()
+	defer ja.mu.Unlock()
+
+	day := msg.Time.UTC().Format("2006-01-02")
+	if ja.f == nil || day != ja.day {
+		if ja.f != nil {
+			ja.f.Close()
+		}
+		name := filepath.Join(ja.dir, archiveFilePrefix+day+archiveFileSuffix)
+		f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			ja.f = nil
+			return err
+		}
+		ja.f = f
+		ja.day = day
+	}
+	_, err = ja.f.Write(line)
+	return err
+}
+
+func (ja *jsonlArchive) Search(term string, limit int) ([]*ArchivedMessage, error) {
+	term = strings.ToLower(strings.TrimSpace(term))
+	if term == "" {
+		return nil, nil
+	}
+
+	infos, err := ioutil.ReadDir(ja.dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, info := range infos {
+		name := info.Name()
+		if strings.HasPrefix(name, archiveFilePrefix) && strings.HasSuffix(name, archiveFileSuffix) {
+			files = append(files, name)
+		}
+	}
+	// newest day first
+	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+
+	var matches []*ArchivedMessage
+	for _, name := range files {
+		dayMatches, err := ja.searchFile(filepath.Join(ja.dir, name), term)
+		if err != nil {
+			return nil, err
+		}
+		// newest message of that day first
+		for i := len(dayMatches) - 1; i >= 0; i-- {
+			matches = append(matches, dayMatches[i])
+			if len(matches) == limit {
+				return matches, nil
+			}
+		}
+	}
+	return matches, nil
+}
+
+func (ja *jsonlArchive) searchFile(name, term string) (matches []*ArchivedMessage, err error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var msg ArchivedMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			// skip partially written lines
+			continue
+		}
+		if strings.Contains(strings.ToLower(msg.Text), term) || strings.ToLower(msg.Nick) == term {
+			matches = append(matches, &msg)
+		}
+	}
+	return matches, scanner.Err()
+}
+
+// archive records a relayed message, if an archive has been configured
+func (bridge *Bridge) archive(msg *ArchivedMessage) {
+	if bridge.archiver == nil {
+		return
+	}
+	if msg.Time.IsZero() {
+		msg.Time = time.Now()
+	}
+	if err := bridge.archiver.Record(msg); err != nil {
+		bridge.log.Error("Failed to archive message", "side", msg.Network, "channel", msg.Channel, "err", err)
+	}
+}
+
+// archiveIRC records a relayed irc message
+func (bridge *Bridge) archiveIRC(line *ircc.Line, text string) {
+	bridge.archive(&ArchivedMessage{
+		Time:    lineTime(line),
+		Network: "irc",
+		Channel: line.Target(),
+		Nick:    line.Nick,
+		Account: line.Tags["account"],
+		MsgID:   line.Tags["msgid"],
+		Text:    text,
+	})
+}
+
+// search runs a search command and returns the lines of the reply
+func (bridge *Bridge) search(term string) []string {
+	if bridge.archiver == nil {
+		return []string{"Search is not available: no archive configured."}
+	}
+	if strings.TrimSpace(term) == "" {
+		return []string{"Usage: search <term>"}
+	}
+	matches, err := bridge.archiver.Search(term, searchLimit)
+	if err != nil {
+		bridge.log.Error("Archive search failed", "term", term, "err", err)
+		return []string{"Search failed."}
+	}
+	if len(matches) == 0 {
+		return []string{fmt.Sprintf("No matches for %q.", term)}
+	}
+	lines := make([]string, len(matches))
+	for i, match := range matches {
+		lines[i] = match.String()
+	}
+	return lines
+}
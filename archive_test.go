@@ -0,0 +1,82 @@
+package slirc
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestJSONLArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "slirc-archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	archive, err := NewJSONLArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	yesterday := time.Now().Add(-24 * time.Hour)
+	msgs := []*ArchivedMessage{
+		{Time: yesterday, Network: "irc", Channel: "#slirctest", Nick: "jdoe", Text: "the build is broken"},
+		{Time: yesterday.Add(time.Minute), Network: "slack", Channel: "slirctest", Nick: "testorizor1", UserID: "U11A2B8C1", Text: "which build?"},
+		{Time: time.Now(), Network: "irc", Channel: "#slirctest", Nick: "jdoe", Text: "Build fixed"},
+	}
+	for _, msg := range msgs {
+		if err := archive.Record(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Errorf("expected one file per day (2), got %v", len(files))
+	}
+
+	matches, err := archive.Search("BUILD", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 2 {
+		t.Fatalf("expected 2 matches, got %v", len(matches))
+	}
+	if matches[0].Text != "Build fixed" || matches[1].Text != "which build?" {
+		t.Errorf("matches not ordered newest first: %v, %v", matches[0], matches[1])
+	}
+	if matches[1].UserID != "U11A2B8C1" || matches[1].Network != "slack" {
+		t.Errorf("archived fields lost: %+v", matches[1])
+	}
+
+	matches, err = archive.Search("nothing like this", searchLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 0 {
+		t.Errorf("expected no matches, got %v", matches)
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	cases := []struct {
+		text, cmd, arg string
+		ok             bool
+	}{
+		{"!search foo bar", "!search", "foo bar", true},
+		{"!search", "!search", "", true},
+		{"!searching foo", "!search", "", false},
+		{"search  term ", "search", "term", true},
+		{"hello !search foo", "!search", "", false},
+	}
+	for _, c := range cases {
+		arg, ok := parseCommand(c.text, c.cmd)
+		if arg != c.arg || ok != c.ok {
+			t.Errorf("parseCommand(%q, %q) - expected: (%q, %v) - got: (%q, %v)", c.text, c.cmd, c.arg, c.ok, arg, ok)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package slirc
+
+import (
+	"fmt"
+
+	"github.com/simonkern/slirc/slack"
+)
+
+// Policies for messages of slack bots and integrations, see Config.SlackBotPolicy
+const (
+	BotsRelay = "relay"
+	BotsDrop  = "drop"
+)
+
+// validateBotPolicy checks Config.SlackBotPolicy
+func validateBotPolicy(policy string) error {
+	switch policy {
+	case "", BotsRelay, BotsDrop:
+		return nil
+	}
+	return fmt.Errorf("SlackBotPolicy must be %q or %q, not %q", BotsRelay, BotsDrop, policy)
+}
+
+// relaySlack decides whether a message posted in the bridged slack channel is relayed to irc
+func (bridge *Bridge) relaySlack(sc *slack.Client, e *slack.Event) bool {
+	// our own messages, and those another slirc instance has already relayed
+	if sc.IsSelfMsg(e) || slack.IsBridged(e) {
+		return false
+	}
+	if !e.IsBot() || bridge.botPolicy != BotsDrop {
+		return true
+	}
+	return e.BotID != "" && bridge.botAllow[e.BotID]
+}
@@ -0,0 +1,35 @@
+package slirc
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/simonkern/slirc/slack"
+)
+
+func TestRelaySlack(t *testing.T) {
+	sc := slack.NewClient("foobar")
+	tests := []struct {
+		name   string
+		raw    string
+		policy string
+		want   bool
+	}{
+		{"user", `{"type":"message","user":"U1","text":"hi"}`, BotsDrop, true},
+		{"bot relayed", `{"type":"message","subtype":"bot_message","bot_id":"B1","username":"CI","text":"build failed"}`, BotsRelay, true},
+		{"bot dropped", `{"type":"message","subtype":"bot_message","bot_id":"B1","username":"CI","text":"build failed"}`, BotsDrop, false},
+		{"bot user dropped", `{"type":"message","user":"U2","bot_id":"B3","text":"hi"}`, BotsDrop, false},
+		{"allowed bot", `{"type":"message","subtype":"bot_message","bot_id":"B2","text":"deployed"}`, BotsDrop, true},
+		{"other bridge", `{"type":"message","user":"U3","bot_id":"B3","text":"[nick]: hi\u2063\u200b\u2063"}`, BotsRelay, false},
+	}
+	for _, tt := range tests {
+		var e slack.Event
+		if err := json.Unmarshal([]byte(tt.raw), &e); err != nil {
+			t.Fatal(err)
+		}
+		bridge := &Bridge{botPolicy: tt.policy, botAllow: map[string]bool{"B2": true}}
+		if got := bridge.relaySlack(sc, &e); got != tt.want {
+			t.Errorf("%s - expected: (%v) - got: (%v)", tt.name, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package slirc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	ircc "github.com/fluffle/goirc/client"
+
+	"github.com/simonkern/slirc/slack"
+)
+
+// Filter actions
+const (
+	FilterDrop    = "drop"    // neither relayed nor archived
+	FilterArchive = "archive" // archived, but not relayed
+	FilterRewrite = "rewrite" // Text matches are replaced by Replace, evaluation continues
+)
+
+// FilterRule matches relayed messages. All fields that are set have to match.
+type FilterRule struct {
+	ID       int    `json:"id"`
+	Network  string `json:"network,omitempty"`  // "irc" or "slack"
+	Nick     string `json:"nick,omitempty"`     // irc nick or slack name, case-insensitive
+	UserID   string `json:"user_id,omitempty"`  // slack user or bot ID
+	Hostmask string `json:"hostmask,omitempty"` // irc nick!ident@host, * and ? are wildcards
+	Text     string `json:"text,omitempty"`     // regular expression
+	Action   string `json:"action"`
+	Replace  string `json:"replace,omitempty"` // replacement for FilterRewrite, may use $1 etc.
+
+	text     *regexp.Regexp
+	hostmask *regexp.Regexp
+}
+
+func (fr *FilterRule) compile() (err error) {
+	switch fr.Action {
+	case FilterDrop, FilterArchive:
+	case FilterRewrite:
+		if fr.Text == "" {
+			return fmt.Errorf("rewrite requires text")
+		}
+	default:
+		return fmt.Errorf("unknown action %q", fr.Action)
+	}
+	if fr.Network != "" && fr.Network != "irc" && fr.Network != "slack" {
+		return fmt.Errorf("unknown network %q", fr.Network)
+	}
+	if fr.Text != "" {
+		if fr.text, err = regexp.Compile(fr.Text); err != nil {
+			return err
+		}
+	}
+	if fr.Hostmask != "" {
+		glob := regexp.QuoteMeta(fr.Hostmask)
+		glob = strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(glob)
+		fr.hostmask = regexp.MustCompile("(?i)^" + glob + "$")
+	}
+	return nil
+}
+
+// String formats the rule in the syntax of the filter add command
+func (fr *FilterRule) String() string {
+	parts := []string{strconv.Itoa(fr.ID) + ":", "action=" + fr.Action}
+	for _, kv := range [][2]string{{"network", fr.Network}, {"nick", fr.Nick}, {"user", fr.UserID}, {"hostmask", fr.Hostmask}, {"text", fr.Text}, {"replace", fr.Replace}} {
+		if kv[1] != "" {
+			parts = append(parts, kv[0]+`="`+kv[1]+`"`)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// filterMsg is the message the rules are evaluated against
+type filterMsg struct {
+	network  string
+	nick     string
+	userID   string
+	hostmask string
+	text     string
+}
+
+func ircFilterMsg(line *ircc.Line, text string) filterMsg {
+	return filterMsg{network: "irc", nick: line.Nick, hostmask: line.Nick + "!" + line.Ident + "@" + line.Host, text: text}
+}
+
+func slackFilterMsg(e *slack.Event, text string) filterMsg {
+	userID := e.UserID
+	if userID == "" {
+		userID = e.BotID
+	}
+	return filterMsg{network: "slack", nick: e.Usernick(), userID: userID, text: text}
+}
+
+func (fr *FilterRule) matches(msg *filterMsg) bool {
+	return (fr.Network == "" || fr.Network == msg.network) &&
+		(fr.Nick == "" || strings.EqualFold(fr.Nick, msg.nick)) &&
+		(fr.UserID == "" || fr.UserID == msg.userID) &&
+		(fr.hostmask == nil || fr.hostmask.MatchString(msg.hostmask)) &&
+		(fr.text == nil || fr.text.MatchString(msg.text))
+}
+
+// Filters is the persisted, ordered list of filter rules of a bridge
+type Filters struct {
+	file string
+
+	mu     sync.RWMutex
+	rules  []*FilterRule
+	nextID int
+}
+
+// LoadFilters reads the rules from file. If the file does not exist, the rules
+// are initialised with rules. If file is empty, the rules are kept in memory only.
+func LoadFilters(file string, rules []FilterRule) (*Filters, error) {
+	fs := &Filters{file: file, nextID: 1}
+	if file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err == nil {
+			rules = nil
+			if err := json.Unmarshal(data, &rules); err != nil {
+				return nil, fmt.Errorf("Failed to parse filter file %s: %v", file, err)
+			}
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	for _, rule := range rules {
+		rule := rule
+		if err := rule.compile(); err != nil {
+			return nil, fmt.Errorf("filter %d: %v", rule.ID, err)
+		}
+		if rule.ID == 0 || rule.ID < fs.nextID {
+			rule.ID = fs.nextID
+		}
+		fs.nextID = rule.ID + 1
+		fs.rules = append(fs.rules, &rule)
+	}
+	return fs, nil
+}
+
+// Rules returns a copy of the rules in evaluation order
+func (fs *Filters) Rules() []FilterRule {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	rules := make([]FilterRule, len(fs.rules))
+	for i, rule := range fs.rules {
+		rules[i] = *rule
+	}
+	return rules
+}
+
+// Add appends rule, assigns it an ID and persists the rules
+func (fs *Filters) Add(rule FilterRule) (id int, err error) {
+	if err := rule.compile(); err != nil {
+		return 0, err
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	rule.ID = fs.nextID
+	fs.nextID++
+	fs.rules = append(fs.rules, &rule)
+	return rule.ID, fs.save()
+}
+
+// Remove deletes the rule with id and persists the rules
+func (fs *Filters) Remove(id int) (ok bool, err error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	for i, rule := range fs.rules {
+		if rule.ID == id {
+			fs.rules = append(fs.rules[:i], fs.rules[i+1:]...)
+			return true, fs.save()
+		}
+	}
+	return false, nil
+}
+
+// apply evaluates the rules in order. It returns the possibly rewritten text and
+// FilterDrop or FilterArchive if the message must not be relayed.
+func (fs *Filters) apply(msg filterMsg) (text, action string) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	for _, rule := range fs.rules {
+		if !rule.matches(&msg) {
+			continue
+		}
+		if rule.Action != FilterRewrite {
+			return msg.text, rule.Action
+		}
+		msg.text = rule.text.ReplaceAllString(msg.text, rule.Replace)
+	}
+	return msg.text, ""
+}
+
+// save writes the rules atomically; the caller must hold the lock
+func (fs *Filters) save() error {
+	if fs.file == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(fs.rules, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(fs.file), ".filters")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), fs.file)
+}
+
+// splitQuoted splits s at spaces, except within double quotes, which are removed
+func splitQuoted(s string) (fields []string, err error) {
+	var field strings.Builder
+	var quoted, inField bool
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inField = true
+		case r == ' ' && !quoted:
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			field.WriteRune(r)
+			inField = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+	return fields, nil
+}
+
+// parseFilterRule parses the arguments of filter add, e.g. action=drop network=irc text="^!"
+func parseFilterRule(args string) (rule FilterRule, err error) {
+	fields, err := splitQuoted(args)
+	if err != nil {
+		return rule, err
+	}
+	for _, field := range fields {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return rule, fmt.Errorf("expected key=value, got %q", field)
+		}
+		switch kv[0] {
+		case "action":
+			rule.Action = kv[1]
+		case "network":
+			rule.Network = kv[1]
+		case "nick":
+			rule.Nick = kv[1]
+		case "user":
+			rule.UserID = kv[1]
+		case "hostmask":
+			rule.Hostmask = kv[1]
+		case "text":
+			rule.Text = kv[1]
+		case "replace":
+			rule.Replace = kv[1]
+		default:
+			return rule, fmt.Errorf("unknown key %q", kv[0])
+		}
+	}
+	return rule, nil
+}
+
+// handleFilterCommand handles the admin commands "filter list", "filter add ..." and "filter del <id>"
+func (bridge *Bridge) handleFilterCommand(text string, admin bool) (reply string, ok bool) {
+	args, ok := parseCommand(text, "filter")
+	if !ok || !admin {
+		return "", false
+	}
+	sub, rest := args, ""
+	if i := strings.IndexByte(args, ' '); i != -1 {
+		sub, rest = args[:i], strings.TrimSpace(args[i+1:])
+	}
+	switch sub {
+	case "list":
+		var lines []string
+		for _, rule := range bridge.filters.Rules() {
+			lines = append(lines, rule.String())
+		}
+		if len(lines) == 0 {
+			return "No filters.", true
+		}
+		return strings.Join(lines, "\n"), true
+	case "add":
+		rule, err := parseFilterRule(rest)
+		if err == nil {
+			err = rule.compile()
+		}
+		if err != nil {
+			return fmt.Sprintf("Invalid filter: %v", err), true
+		}
+		id, err := bridge.filters.Add(rule)
+		if err != nil {
+			return fmt.Sprintf("Failed to save filters: %v", err), true
+		}
+		return fmt.Sprintf("Added filter %d.", id), true
+	case "del":
+		id, err := strconv.Atoi(rest)
+		if err != nil {
+			break
+		}
+		found, err := bridge.filters.Remove(id)
+		if err != nil {
+			return fmt.Sprintf("Failed to save filters: %v", err), true
+		}
+		if !found {
+			return fmt.Sprintf("No filter %d.", id), true
+		}
+		return fmt.Sprintf("Removed filter %d.", id), true
+	}
+	return `Usage: filter list | filter del <id> | filter add action=drop|archive|rewrite [network=irc|slack] [nick=..] [user=..] [hostmask=..] [text="regexp"] [replace=".."]`, true
+}
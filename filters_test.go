@@ -0,0 +1,90 @@
+package slirc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFilters(t *testing.T) {
+	fs, err := LoadFilters("", []FilterRule{
+		{Network: "irc", Text: `^!`, Action: FilterDrop},
+		{Hostmask: "*!*@*.bots.example.org", Action: FilterArchive},
+		{Network: "slack", UserID: "B1", Text: `(?i)password: \S+`, Replace: "password: ***", Action: FilterRewrite},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		msg        filterMsg
+		wantText   string
+		wantAction string
+	}{
+		{"bot command", filterMsg{network: "irc", nick: "jdoe", hostmask: "jdoe!~j@host.example.org", text: "!weather"}, "!weather", FilterDrop},
+		{"bot command on slack", filterMsg{network: "slack", nick: "jdoe", text: "!weather"}, "!weather", ""},
+		{"noisy bot", filterMsg{network: "irc", nick: "feeder", hostmask: "feeder!~f@rss.bots.example.org", text: "new post"}, "new post", FilterArchive},
+		{"rewrite", filterMsg{network: "slack", userID: "B1", text: "Password: hunter2 set"}, "password: *** set", ""},
+		{"other user", filterMsg{network: "slack", userID: "U1", text: "Password: hunter2 set"}, "Password: hunter2 set", ""},
+	}
+	for _, tt := range tests {
+		text, action := fs.apply(tt.msg)
+		if text != tt.wantText || action != tt.wantAction {
+			t.Errorf("%s - expected: (%q, %q) - got: (%q, %q)", tt.name, tt.wantText, tt.wantAction, text, action)
+		}
+	}
+}
+
+func TestFilterCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "slirc-filters")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "filters.json")
+
+	filters, err := LoadFilters(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bridge := &Bridge{filters: filters}
+
+	if _, ok := bridge.handleFilterCommand("filter list", false); ok {
+		t.Error("filter is available to non-admins")
+	}
+	if reply, _ := bridge.handleFilterCommand(`filter add action=drop nick=feeder text="^\[rss\] "`, true); reply != "Added filter 1." {
+		t.Errorf("filter add - got: (%v)", reply)
+	}
+	if reply, _ := bridge.handleFilterCommand(`filter add action=explode`, true); !strings.HasPrefix(reply, "Invalid filter") {
+		t.Errorf("filter add with an invalid action - got: (%v)", reply)
+	}
+	if reply, _ := bridge.handleFilterCommand(`filter add action=drop text="("`, true); !strings.HasPrefix(reply, "Invalid filter") {
+		t.Errorf("filter add with an invalid regexp - got: (%v)", reply)
+	}
+	want := `1: action=drop nick="feeder" text="^\[rss\] "`
+	if reply, _ := bridge.handleFilterCommand("filter list", true); reply != want {
+		t.Errorf("filter list - expected: (%v) - got: (%v)", want, reply)
+	}
+
+	// survives a restart
+	filters, err = LoadFilters(file, []FilterRule{{Action: FilterDrop}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, action := filters.apply(filterMsg{network: "irc", nick: "Feeder", text: "[rss] new post"}); action != FilterDrop {
+		t.Errorf("persisted filter did not match - got: (%q)", action)
+	}
+	if _, action := filters.apply(filterMsg{network: "irc", nick: "jdoe", text: "hello"}); action != "" {
+		t.Errorf("config rules must not be used if the filter file exists - got: (%q)", action)
+	}
+
+	bridge.filters = filters
+	if reply, _ := bridge.handleFilterCommand("filter del 1", true); reply != "Removed filter 1." {
+		t.Errorf("filter del - got: (%v)", reply)
+	}
+	if reply, _ := bridge.handleFilterCommand("filter list", true); reply != "No filters." {
+		t.Errorf("filter list after del - got: (%v)", reply)
+	}
+}
@@ -0,0 +1,183 @@
+package slirc
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// slackPongMaxAge is the maximum age of the last Slack pong before the
+// Slack side is considered unhealthy. Pings are sent roughly every 54s.
+const slackPongMaxAge = 2 * time.Minute
+
+// maxDowntime is the time a side may be disconnected before /healthz reports
+// the bridge as unhealthy, e.g. because it is stuck in a reconnect loop
+const maxDowntime = 5 * time.Minute
+
+// sideHealth keeps track of connection state for one side of the bridge
+type sideHealth struct {
+	mu          sync.RWMutex
+	connectedAt time.Time
+	lostAt      time.Time // when the connection was lost, zero if it never was
+	lastErr     error
+	lastErrAt   time.Time
+	joined      map[string]bool // irc only
+}
+
+func (sh *sideHealth) setConnected() {
+	sh.mu.Lock()
+	sh.connectedAt = time.Now()
+	sh.lostAt = time.Time{}
+	sh.mu.Unlock()
+}
+
+func (sh *sideHealth) setDisconnected() {
+	sh.mu.Lock()
+	if !sh.connectedAt.IsZero() {
+		sh.lostAt = time.Now()
+	}
+	sh.connectedAt = time.Time{}
+	sh.joined = nil
+	sh.mu.Unlock()
+}
+
+func (sh *sideHealth) setError(err error) {
+	sh.mu.Lock()
+	sh.lastErr = err
+	sh.lastErrAt = time.Now()
+	sh.mu.Unlock()
+}
+
+func (sh *sideHealth) setJoined(channel string, joined bool) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if sh.joined == nil {
+		sh.joined = make(map[string]bool)
+	}
+	sh.joined[channel] = joined
+}
+
+func (sh *sideHealth) isJoined(channel string) bool {
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	return sh.joined[channel]
+}
+
+// downtime returns how long the side has been disconnected, counting from started
+// if it has never been connected
+func (sh *sideHealth) downtime(started time.Time) time.Duration {
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	switch {
+	case !sh.connectedAt.IsZero():
+		return 0
+	case !sh.lostAt.IsZero():
+		return time.Since(sh.lostAt)
+	}
+	return time.Since(started)
+}
+
+// status fills in uptime, downtime and last error; readiness is decided by the caller
+func (sh *sideHealth) status(started time.Time) (st SideStatus) {
+	if down := sh.downtime(started); down > 0 {
+		st.Downtime = down.Truncate(time.Second).String()
+		st.healthy = down <= maxDowntime
+	} else {
+		st.healthy = true
+	}
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	if !sh.connectedAt.IsZero() {
+		st.Uptime = time.Since(sh.connectedAt).Truncate(time.Second).String()
+	}
+	if sh.lastErr != nil {
+		st.LastError = sh.lastErr.Error()
+		at := sh.lastErrAt
+		st.LastErrorAt = &at
+	}
+	return st
+}
+
+// SideStatus describes the health of either the slack or the irc side
+type SideStatus struct {
+	Ready       bool       `json:"ready"`
+	Reasons     []string   `json:"reasons,omitempty"`
+	Uptime      string     `json:"uptime,omitempty"`
+	Downtime    string     `json:"downtime,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+	QueueDepth  int        `json:"queue_depth,omitempty"` // slack only, messages waiting to be sent
+
+	healthy bool
+}
+
+// HealthStatus is the JSON body served by /healthz and /readyz
+type HealthStatus struct {
+	// Healthy is false if a side has been disconnected for longer than maxDowntime
+	Healthy bool       `json:"healthy"`
+	Ready   bool       `json:"ready"`
+	Uptime  string     `json:"uptime"`
+	Slack   SideStatus `json:"slack"`
+	IRC     SideStatus `json:"irc"`
+}
+
+// Health reports the current readiness of both sides of the bridge
+func (bridge *Bridge) Health() *HealthStatus {
+	hs := &HealthStatus{Uptime: time.Since(bridge.started).Truncate(time.Second).String()}
+
+	hs.Slack = bridge.slackHealth.status(bridge.started)
+	if !bridge.slack.Connected() {
+		hs.Slack.Reasons = append(hs.Slack.Reasons, "websocket not connected")
+	} else if age := time.Since(bridge.slack.LastPong()); age > slackPongMaxAge {
+		hs.Slack.Reasons = append(hs.Slack.Reasons, "no pong for "+age.Truncate(time.Second).String())
+	}
+	hs.Slack.Ready = len(hs.Slack.Reasons) == 0
+	hs.Slack.QueueDepth = bridge.slack.QueueDepth()
+
+	hs.IRC = bridge.ircHealth.status(bridge.started)
+	if !bridge.irc.Connected() {
+		hs.IRC.Reasons = append(hs.IRC.Reasons, "not connected")
+	} else if hs.IRC.Uptime == "" {
+		hs.IRC.Reasons = append(hs.IRC.Reasons, "not registered")
+	}
+	if !bridge.ircHealth.isJoined(bridge.IRCChan) {
+		hs.IRC.Reasons = append(hs.IRC.Reasons, "not joined to "+bridge.IRCChan)
+	}
+	hs.IRC.Ready = len(hs.IRC.Reasons) == 0
+
+	hs.Ready = hs.Slack.Ready && hs.IRC.Ready
+	hs.Healthy = hs.Slack.healthy && hs.IRC.healthy
+	return hs
+}
+
+// HealthHandler returns an http.Handler serving /healthz, /readyz and /metrics.
+// /healthz answers 503 if a side has been disconnected for longer than maxDowntime,
+// /readyz answers 503 unless both sides are connected.
+func (bridge *Bridge) HealthHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		hs := bridge.Health()
+		code := http.StatusOK
+		if !hs.Healthy {
+			code = http.StatusServiceUnavailable
+		}
+		writeHealth(w, code, hs)
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		hs := bridge.Health()
+		code := http.StatusOK
+		if !hs.Ready {
+			code = http.StatusServiceUnavailable
+		}
+		writeHealth(w, code, hs)
+	})
+	mux.HandleFunc("/metrics", bridge.serveMetrics)
+	return mux
+}
+
+func writeHealth(w http.ResponseWriter, code int, hs *HealthStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(hs)
+}
@@ -0,0 +1,94 @@
+package slirc
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ircc "github.com/fluffle/goirc/client"
+
+	"github.com/simonkern/slirc/slack"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	bridge := &Bridge{
+		SlackChan: "slirctest",
+		IRCChan:   "#slirctest",
+		slack:     slack.NewClient("foobar"),
+		irc:       ircc.Client(ircc.NewConfig("slirc")),
+		started:   time.Now(),
+	}
+	bridge.slackHealth.setError(errors.New("invalid_auth"))
+
+	srv := httptest.NewServer(bridge.HealthHandler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("healthz - expected: (%v) - got: (%v)", http.StatusOK, resp.StatusCode)
+	}
+
+	resp, err = http.Get(srv.URL + "/readyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("readyz - expected: (%v) - got: (%v)", http.StatusServiceUnavailable, resp.StatusCode)
+	}
+
+	var hs HealthStatus
+	if err := json.NewDecoder(resp.Body).Decode(&hs); err != nil {
+		t.Fatal(err)
+	}
+	if hs.Ready || hs.Slack.Ready || hs.IRC.Ready {
+		t.Errorf("expected nothing to be ready, got %+v", hs)
+	}
+	if len(hs.Slack.Reasons) == 0 || len(hs.IRC.Reasons) == 0 {
+		t.Errorf("expected reasons for both sides, got %+v", hs)
+	}
+	if hs.Slack.LastError != "invalid_auth" {
+		t.Errorf("Slack last error - expected: (invalid_auth) - got: (%v)", hs.Slack.LastError)
+	}
+}
+
+func TestHealthDowntime(t *testing.T) {
+	bridge := &Bridge{
+		slack:   slack.NewClient("foobar"),
+		irc:     ircc.Client(ircc.NewConfig("slirc")),
+		started: time.Now().Add(-time.Hour),
+	}
+	bridge.ircHealth.setConnected()
+	if hs := bridge.Health(); hs.Healthy || hs.Slack.Downtime == "" || hs.IRC.Downtime != "" {
+		t.Errorf("slack never connected - expected unhealthy - got: %+v", hs)
+	}
+
+	bridge.slackHealth.setConnected()
+	bridge.slackHealth.setDisconnected()
+	if hs := bridge.Health(); !hs.Healthy {
+		t.Errorf("slack reconnecting for a moment - expected healthy - got: %+v", hs)
+	}
+	bridge.slackHealth.mu.Lock()
+	bridge.slackHealth.lostAt = time.Now().Add(-maxDowntime - time.Minute)
+	bridge.slackHealth.mu.Unlock()
+	// reconnect attempts do not reset the downtime
+	bridge.slackHealth.setDisconnected()
+
+	srv := httptest.NewServer(bridge.HealthHandler())
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("healthz after %v downtime - expected: (%v) - got: (%v)", maxDowntime, http.StatusServiceUnavailable, resp.StatusCode)
+	}
+}
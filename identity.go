@@ -0,0 +1,261 @@
+package slirc
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// linkCodeTTL is the time a user has to confirm a link code
+const linkCodeTTL = 10 * time.Minute
+
+// Link connects an irc nick with a slack user ID
+type Link struct {
+	IRCNick   string `json:"irc_nick"`
+	SlackUser string `json:"slack_user"`
+}
+
+type pendingLink struct {
+	Link
+	expires time.Time
+}
+
+// Identities is the persisted mapping between irc nicks and slack users
+type Identities struct {
+	file string
+
+	mu      sync.RWMutex
+	byNick  map[string]string // lowercased irc nick -> slack user ID
+	byUser  map[string]string // slack user ID -> irc nick
+	pending map[string]*pendingLink
+}
+
+// LoadIdentities reads the identity map from file. A missing file yields an empty map.
+// If file is empty, the map is kept in memory only.
+func LoadIdentities(file string) (*Identities, error) {
+	ids := &Identities{
+		file:    file,
+		byNick:  make(map[string]string),
+		byUser:  make(map[string]string),
+		pending: make(map[string]*pendingLink),
+	}
+	if file == "" {
+		return ids, nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return ids, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var links []Link
+	if err := json.Unmarshal(data, &links); err != nil {
+		return nil, fmt.Errorf("Failed to parse identity file %s: %v", file, err)
+	}
+	for _, link := range links {
+		ids.set(link.IRCNick, link.SlackUser)
+	}
+	return ids, nil
+}
+
+// SlackUser returns the slack user ID linked to nick
+func (ids *Identities) SlackUser(nick string) (userID string, ok bool) {
+	ids.mu.RLock()
+	defer ids.mu.RUnlock()
+	userID, ok = ids.byNick[strings.ToLower(nick)]
+	return userID, ok
+}
+
+// IRCNick returns the irc nick linked to the slack user ID
+func (ids *Identities) IRCNick(userID string) (nick string, ok bool) {
+	ids.mu.RLock()
+	defer ids.mu.RUnlock()
+	nick, ok = ids.byUser[userID]
+	return nick, ok
+}
+
+// Links returns all links, sorted by irc nick
+func (ids *Identities) Links() []Link {
+	ids.mu.RLock()
+	defer ids.mu.RUnlock()
+	links := make([]Link, 0, len(ids.byUser))
+	for userID, nick := range ids.byUser {
+		links = append(links, Link{IRCNick: nick, SlackUser: userID})
+	}
+	sort.Slice(links, func(i, j int) bool { return strings.ToLower(links[i].IRCNick) < strings.ToLower(links[j].IRCNick) })
+	return links
+}
+
+// Set links nick and userID, replacing existing links of either, and persists the map
+func (ids *Identities) Set(nick, userID string) error {
+	ids.mu.Lock()
+	defer ids.mu.Unlock()
+	ids.set(nick, userID)
+	return ids.save()
+}
+
+// Unset removes the link of nick and persists the map
+func (ids *Identities) Unset(nick string) error {
+	ids.mu.Lock()
+	defer ids.mu.Unlock()
+	ids.unset(nick)
+	return ids.save()
+}
+
+// Request creates a link code for nick and userID that has to be confirmed using Confirm
+func (ids *Identities) Request(nick, userID string) (code string, err error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	code = fmt.Sprintf("%06d", n.Int64())
+
+	ids.mu.Lock()
+	defer ids.mu.Unlock()
+	now := time.Now()
+	for c, pl := range ids.pending {
+		if now.After(pl.expires) || pl.SlackUser == userID {
+			delete(ids.pending, c)
+		}
+	}
+	ids.pending[code] = &pendingLink{Link: Link{IRCNick: nick, SlackUser: userID}, expires: now.Add(linkCodeTTL)}
+	return code, nil
+}
+
+// Confirm links the nick of a pending request if code has been requested by userID
+func (ids *Identities) Confirm(userID, code string) (nick string, err error) {
+	ids.mu.Lock()
+	defer ids.mu.Unlock()
+	pl, ok := ids.pending[code]
+	if !ok || pl.SlackUser != userID || time.Now().After(pl.expires) {
+		return "", fmt.Errorf("unknown or expired code")
+	}
+	delete(ids.pending, code)
+	ids.set(pl.IRCNick, pl.SlackUser)
+	return pl.IRCNick, ids.save()
+}
+
+func (ids *Identities) set(nick, userID string) {
+	ids.unset(nick)
+	if oldNick, ok := ids.byUser[userID]; ok {
+		ids.unset(oldNick)
+	}
+	ids.byNick[strings.ToLower(nick)] = userID
+	ids.byUser[userID] = nick
+}
+
+func (ids *Identities) unset(nick string) {
+	key := strings.ToLower(nick)
+	if userID, ok := ids.byNick[key]; ok {
+		delete(ids.byUser, userID)
+		delete(ids.byNick, key)
+	}
+}
+
+// save writes the map atomically; the caller must hold the lock
+func (ids *Identities) save() error {
+	if ids.file == "" {
+		return nil
+	}
+	links := make([]Link, 0, len(ids.byUser))
+	for userID, nick := range ids.byUser {
+		links = append(links, Link{IRCNick: nick, SlackUser: userID})
+	}
+	data, err := json.MarshalIndent(links, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(ids.file), ".identities")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), ids.file)
+}
+
+// ircMentionRe matches "nick:" or "nick," at the start of a message and "@nick" anywhere
+var ircMentionRe = regexp.MustCompile(`^([^\s:,]+)[:,]|@([^\s:,.!?]+)`)
+
+// slackifyMentions replaces mentions of linked irc nicks with slack highlights
+func (ids *Identities) slackifyMentions(text string) string {
+	return ircMentionRe.ReplaceAllStringFunc(text, func(match string) string {
+		sub := ircMentionRe.FindStringSubmatch(match)
+		nick, suffix := sub[2], ""
+		if sub[1] != "" {
+			nick, suffix = sub[1], match[len(match)-1:]
+		}
+		userID, ok := ids.SlackUser(nick)
+		if !ok {
+			return match
+		}
+		return fmt.Sprintf("<@%s>%s", userID, suffix)
+	})
+}
+
+// handleIdentityCommand handles "link", "confirm" and, for admins, "identity" commands
+func (bridge *Bridge) handleIdentityCommand(userID, text string, admin bool) (reply string, ok bool) {
+	if nick, ok := parseCommand(text, "link"); ok {
+		if nick == "" || strings.ContainsAny(nick, " #,") {
+			return "Usage: link <ircnick>", true
+		}
+		code, err := bridge.identities.Request(nick, userID)
+		if err != nil {
+			bridge.log.Error("Failed to create link code", "side", "slack", "user", userID, "err", err)
+			return "Linking failed.", true
+		}
+		bridge.irc.Privmsg(nick, fmt.Sprintf("Slack user %s wants to link with your nick. If that is you, tell the bridge on Slack: confirm %s", bridge.slack.UserName(userID), code))
+		return fmt.Sprintf("I have sent a code to %s on IRC. Confirm with: confirm <code>", nick), true
+	}
+	if code, ok := parseCommand(text, "confirm"); ok {
+		nick, err := bridge.identities.Confirm(userID, code)
+		if err != nil {
+			return fmt.Sprintf("Linking failed: %v", err), true
+		}
+		return fmt.Sprintf("You are now linked with %s on IRC.", nick), true
+	}
+	args, ok := parseCommand(text, "identity")
+	if !ok || !admin {
+		return "", false
+	}
+	fields := strings.Fields(args)
+	switch {
+	case len(fields) == 1 && fields[0] == "list":
+		var lines []string
+		for _, link := range bridge.identities.Links() {
+			lines = append(lines, fmt.Sprintf("%s <-> %s (%s)", link.IRCNick, bridge.slack.UserName(link.SlackUser), link.SlackUser))
+		}
+		if len(lines) == 0 {
+			return "No linked identities.", true
+		}
+		return strings.Join(lines, "\n"), true
+	case len(fields) == 3 && fields[0] == "set":
+		if err := bridge.identities.Set(fields[1], fields[2]); err != nil {
+			return fmt.Sprintf("Failed to save identities: %v", err), true
+		}
+		return fmt.Sprintf("Linked %s with %s.", fields[1], fields[2]), true
+	case len(fields) == 2 && fields[0] == "unset":
+		if err := bridge.identities.Unset(fields[1]); err != nil {
+			return fmt.Sprintf("Failed to save identities: %v", err), true
+		}
+		return fmt.Sprintf("Unlinked %s.", fields[1]), true
+	}
+	return "Usage: identity list | identity set <ircnick> <slackuserid> | identity unset <ircnick>", true
+}
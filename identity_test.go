@@ -0,0 +1,75 @@
+package slirc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIdentities(t *testing.T) {
+	dir, err := ioutil.TempDir("", "slirc-identities")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "identities.json")
+
+	ids, err := LoadIdentities(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := ids.Request("jdoe", "U11A2B8C1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ids.Confirm("U11A2BBCK", code); err == nil {
+		t.Error("Confirm accepted a code requested by another user")
+	}
+	nick, err := ids.Confirm("U11A2B8C1", code)
+	if err != nil || nick != "jdoe" {
+		t.Fatalf("Confirm - expected: (jdoe, nil) - got: (%v, %v)", nick, err)
+	}
+	if _, err := ids.Confirm("U11A2B8C1", code); err == nil {
+		t.Error("Confirm accepted a code twice")
+	}
+
+	if err := ids.Set("Tester2", "U11A2BBCK"); err != nil {
+		t.Fatal(err)
+	}
+	// relinking a user drops the old nick
+	if err := ids.Set("tester2_", "U11A2BBCK"); err != nil {
+		t.Fatal(err)
+	}
+
+	// reload from disk
+	ids, err = LoadIdentities(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID, ok := ids.SlackUser("JDOE"); !ok || userID != "U11A2B8C1" {
+		t.Errorf("SlackUser - expected: (U11A2B8C1) - got: (%v)", userID)
+	}
+	if _, ok := ids.SlackUser("tester2"); ok {
+		t.Error("old nick of relinked user is still linked")
+	}
+	if nick, ok := ids.IRCNick("U11A2BBCK"); !ok || nick != "tester2_" {
+		t.Errorf("IRCNick - expected: (tester2_) - got: (%v)", nick)
+	}
+
+	got := ids.slackifyMentions("jdoe: ping @tester2_, and @nobody")
+	want := "<@U11A2B8C1>: ping <@U11A2BBCK>, and @nobody"
+	if got != want {
+		t.Logf("Got: %v", got)
+		t.Logf("Want: %v", want)
+		t.Fail()
+	}
+
+	if err := ids.Unset("jdoe"); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids.Links()) != 1 {
+		t.Errorf("expected 1 link after Unset, got %v", ids.Links())
+	}
+}
@@ -0,0 +1,207 @@
+package slirc
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	ircc "github.com/fluffle/goirc/client"
+)
+
+// IRCv3 capabilities requested by the bridge, see https://ircv3.net/irc/
+// All of them are optional, the bridge falls back to plain RFC1459 behaviour
+// for every capability the server does not offer.
+const (
+	capServerTime  = "server-time"
+	capMessageTags = "message-tags"
+	capEchoMessage = "echo-message"
+	capAccountTag  = "account-tag"
+)
+
+var ircCapabilities = []string{capServerTime, capMessageTags, capEchoMessage, capAccountTag}
+
+const (
+	// replayThreshold is the age after which a message is considered replayed
+	// (e.g. by a bouncer) and shown with its original timestamp
+	replayThreshold = time.Minute
+	// echoTimeout is the time the server has to echo our messages with echo-message
+	echoTimeout = 30 * time.Second
+	// msgIDCacheSize is the number of irc messages remembered for reply correlation
+	msgIDCacheSize = 256
+	// replyExcerptLen is the number of characters of the original message shown for replies
+	replyExcerptLen = 30
+)
+
+// lineTime returns the server-time of line, or the time it has been received
+func lineTime(line *ircc.Line) time.Time {
+	if ts, ok := line.Tags["time"]; ok {
+		if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+			return t
+		}
+	}
+	if !line.Time.IsZero() {
+		return line.Time
+	}
+	return time.Now()
+}
+
+// ircNickLabel returns the nick of line's sender, marked with the services
+// account it is logged in to, if the server sends account-tag
+func ircNickLabel(line *ircc.Line) string {
+	account, ok := line.Tags["account"]
+	if !ok || account == "" || account == "*" {
+		return line.Nick
+	}
+	if strings.EqualFold(account, line.Nick) {
+		return line.Nick + " ✓"
+	}
+	return line.Nick + " ✓" + account
+}
+
+// replyRef returns the msgid a message replies to, see https://ircv3.net/specs/client-tags/reply
+func replyRef(line *ircc.Line) string {
+	if ref, ok := line.Tags["+reply"]; ok {
+		return ref
+	}
+	return line.Tags["+draft/reply"]
+}
+
+// formatIRCMessage formats an irc message for slack, text is the already translated message
+func (bridge *Bridge) formatIRCMessage(line *ircc.Line, text string) string {
+	var prefix string
+	if t := lineTime(line); time.Since(t) > replayThreshold {
+		prefix = "[" + t.Local().Format("2006-01-02 15:04") + "] "
+	}
+	if summary, ok := bridge.msgIDs.get(replyRef(line)); ok {
+		text = fmt.Sprintf("(re %s) %s", summary, text)
+	}
+	bridge.msgIDs.add(line.Tags["msgid"], line.Nick+": "+excerpt(line.Text(), replyExcerptLen))
+	return fmt.Sprintf("%s[%s]: %s", prefix, ircNickLabel(line), text)
+}
+
+func excerpt(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return strings.TrimSpace(string(runes[:n])) + "…"
+}
+
+// msgIDCache remembers a summary of the last relayed irc messages by msgid
+type msgIDCache struct {
+	mu    sync.Mutex
+	order []string
+	byID  map[string]string
+}
+
+func newMsgIDCache() *msgIDCache {
+	return &msgIDCache{byID: make(map[string]string)}
+}
+
+func (mc *msgIDCache) add(id, summary string) {
+	if id == "" {
+		return
+	}
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if _, ok := mc.byID[id]; !ok {
+		mc.order = append(mc.order, id)
+	}
+	mc.byID[id] = summary
+	if len(mc.order) > msgIDCacheSize {
+		delete(mc.byID, mc.order[0])
+		mc.order = mc.order[1:]
+	}
+}
+
+func (mc *msgIDCache) get(id string) (summary string, ok bool) {
+	if id == "" {
+		return "", false
+	}
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	summary, ok = mc.byID[id]
+	return summary, ok
+}
+
+type pendingEcho struct {
+	target string
+	text   string
+	sent   time.Time
+}
+
+// echoTracker keeps track of messages we sent until the server echoes them
+type echoTracker struct {
+	mu      sync.Mutex
+	pending []pendingEcho
+}
+
+func (et *echoTracker) sent(target, text string) {
+	et.mu.Lock()
+	defer et.mu.Unlock()
+	et.pending = append(et.pending, pendingEcho{target: target, text: text, sent: time.Now()})
+}
+
+// ircSplitSuffix is appended by the irc client to all but the last part of a split message
+const ircSplitSuffix = "..."
+
+// echoed marks the oldest matching message as delivered. Long messages may have been split
+// by the irc client, therefore the echoed text, without the client's split suffix, only has
+// to be a prefix of the sent text. The first part confirms the message.
+func (et *echoTracker) echoed(target, text string) bool {
+	et.mu.Lock()
+	defer et.mu.Unlock()
+	part := strings.TrimSpace(strings.TrimSuffix(text, ircSplitSuffix))
+	for i, pe := range et.pending {
+		if strings.EqualFold(pe.target, target) && (pe.text == text || (part != "" && strings.HasPrefix(pe.text, part))) {
+			et.pending = append(et.pending[:i], et.pending[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// expired removes and returns messages that have not been echoed within echoTimeout
+func (et *echoTracker) expired() (lost []pendingEcho) {
+	et.mu.Lock()
+	defer et.mu.Unlock()
+	var pending []pendingEcho
+	for _, pe := range et.pending {
+		if time.Since(pe.sent) > echoTimeout {
+			lost = append(lost, pe)
+		} else {
+			pending = append(pending, pe)
+		}
+	}
+	et.pending = pending
+	return lost
+}
+
+// reset forgets all pending messages, e.g. after a reconnect
+func (et *echoTracker) reset() {
+	et.mu.Lock()
+	et.pending = nil
+	et.mu.Unlock()
+}
+
+// ircPrivmsg sends a relayed message to irc and, with echo-message, tracks its delivery
+func (bridge *Bridge) ircPrivmsg(target, text string) {
+	if bridge.irc.HasCapability(capEchoMessage) {
+		bridge.echoes.sent(target, text)
+	}
+	bridge.irc.Privmsg(target, text)
+}
+
+// checkEchoes periodically reports relayed messages the server did not confirm
+func (bridge *Bridge) checkEchoes() {
+	ticker := time.NewTicker(echoTimeout)
+	defer ticker.Stop()
+	for range ticker.C {
+		for _, pe := range bridge.echoes.expired() {
+			err := fmt.Errorf("message to %s not confirmed by the server within %v", pe.target, echoTimeout)
+			bridge.ircHealth.setError(err)
+			bridge.log.Warn("IRC message possibly not delivered", "side", "irc", "channel", pe.target, "err", err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package slirc
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	ircc "github.com/fluffle/goirc/client"
+)
+
+func TestFormatIRCMessage(t *testing.T) {
+	bridge := &Bridge{msgIDs: newMsgIDCache()}
+
+	first := &ircc.Line{Nick: "jdoe", Cmd: "PRIVMSG", Args: []string{"#slirctest", "is the build broken on master again?"},
+		Tags: map[string]string{"msgid": "abc", "account": "jdoe"}}
+	if got, want := bridge.formatIRCMessage(first, first.Text()), "[jdoe ✓]: is the build broken on master again?"; got != want {
+		t.Errorf("Got: %v - Want: %v", got, want)
+	}
+
+	reply := &ircc.Line{Nick: "alice", Cmd: "PRIVMSG", Args: []string{"#slirctest", "yes"},
+		Tags: map[string]string{"+draft/reply": "abc", "account": "alice_"}}
+	if got, want := bridge.formatIRCMessage(reply, reply.Text()), "[alice ✓alice_]: (re jdoe: is the build broken on master…) yes"; got != want {
+		t.Errorf("Got: %v - Want: %v", got, want)
+	}
+
+	sent := time.Date(2026, 10, 18, 14, 3, 0, 0, time.UTC)
+	replayed := &ircc.Line{Nick: "bob", Cmd: "PRIVMSG", Args: []string{"#slirctest", "morning"},
+		Tags: map[string]string{"time": sent.Format(time.RFC3339Nano)}}
+	if !lineTime(replayed).Equal(sent) {
+		t.Errorf("lineTime - expected: (%v) - got: (%v)", sent, lineTime(replayed))
+	}
+	want := "[" + sent.Local().Format("2006-01-02 15:04") + "] [bob]: morning"
+	if got := bridge.formatIRCMessage(replayed, replayed.Text()); got != want {
+		t.Errorf("Got: %v - Want: %v", got, want)
+	}
+}
+
+func TestEchoTracker(t *testing.T) {
+	var et echoTracker
+	et.sent("#slirctest", "[alice]: a rather long message that got split")
+	et.sent("#slirctest", "[bob]: hi")
+
+	if !et.echoed("#SlircTest", "[alice]: a rather long") {
+		t.Error("split message was not matched")
+	}
+	// goirc appends "..." to the parts of a split message
+	et.sent("#slirctest", "[dave]: "+strings.Repeat("word ", 100))
+	if !et.echoed("#slirctest", "[dave]: "+strings.Repeat("word ", 80)+"...") {
+		t.Error("first part of a message split by the irc client was not matched")
+	}
+	if et.echoed("#slirctest", "[carol]: unknown") {
+		t.Error("unknown message was matched")
+	}
+	if lost := et.expired(); len(lost) != 0 {
+		t.Errorf("expected no expired messages, got %v", lost)
+	}
+	et.pending[0].sent = time.Now().Add(-2 * echoTimeout)
+	if lost := et.expired(); len(lost) != 1 || lost[0].text != "[bob]: hi" {
+		t.Errorf("expected [bob]: hi to expire, got %v", lost)
+	}
+}
@@ -0,0 +1,64 @@
+package slirc
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// metrics holds the counters of a bridge, served in the Prometheus text format by /metrics
+type metrics struct {
+	mu       sync.Mutex
+	counters map[string]map[string]int64 // name -> labels -> value
+	help     map[string]string
+}
+
+// inc increments the counter name with labels, e.g. `direction="slack_to_irc"`
+func (m *metrics) inc(name, help, labels string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.counters == nil {
+		m.counters = make(map[string]map[string]int64)
+		m.help = make(map[string]string)
+	}
+	if m.counters[name] == nil {
+		m.counters[name] = make(map[string]int64)
+		m.help[name] = help
+	}
+	m.counters[name][labels]++
+}
+
+// get returns the value of the counter name with labels
+func (m *metrics) get(name, labels string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counters[name][labels]
+}
+
+func (m *metrics) writeTo(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.counters))
+	for name := range m.counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, m.help[name], name)
+		labels := make([]string, 0, len(m.counters[name]))
+		for l := range m.counters[name] {
+			labels = append(labels, l)
+		}
+		sort.Strings(labels)
+		for _, l := range labels {
+			fmt.Fprintf(w, "%s{%s} %d\n", name, l, m.counters[name][l])
+		}
+	}
+}
+
+func (bridge *Bridge) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	bridge.metrics.writeTo(w)
+}
@@ -0,0 +1,330 @@
+package slirc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/simonkern/slirc/slack"
+)
+
+const (
+	// defaultMirrorTTL is used if Config.FileMirrorTTL is not set
+	defaultMirrorTTL = 7 * 24 * time.Hour
+	// defaultMirrorMaxSize is used if Config.FileMirrorMaxSize is not set
+	defaultMirrorMaxSize = 10 << 20
+	// mirrorExpireInterval is the time between two checks for expired mirrored files
+	mirrorExpireInterval = time.Hour
+)
+
+// defaultMirrorTypes is used if Config.FileMirrorTypes is not set
+var defaultMirrorTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp", "text/plain", "application/pdf"}
+
+// mirroredFile is stored next to the content of a mirrored file
+type mirroredFile struct {
+	Name     string `json:"name"`
+	Mimetype string `json:"mimetype"`
+}
+
+// fileMirror stores files shared on slack in a content-addressed directory and signs links to them
+type fileMirror struct {
+	dir     string
+	baseURL string
+	secret  []byte
+	ttl     time.Duration
+	maxSize int64
+	types   []string
+
+	mu       sync.Mutex
+	mirrored map[string]bool     // file ID + channel ID, false while the file is being mirrored
+	bySum    map[string][]string // keys of mirrored by the SHA-256 of the stored file
+}
+
+func newFileMirror(c *Config) (*fileMirror, error) {
+	if err := os.MkdirAll(c.FileMirrorDir, 0700); err != nil {
+		return nil, fmt.Errorf("FileMirrorDir: %v", err)
+	}
+	fm := &fileMirror{
+		dir:      c.FileMirrorDir,
+		baseURL:  strings.TrimSuffix(c.FileMirrorURL, "/"),
+		secret:   []byte(c.FileMirrorSecret),
+		ttl:      c.FileMirrorTTL,
+		maxSize:  c.FileMirrorMaxSize,
+		types:    c.FileMirrorTypes,
+		mirrored: make(map[string]bool),
+		bySum:    make(map[string][]string),
+	}
+	if fm.ttl <= 0 {
+		fm.ttl = defaultMirrorTTL
+	}
+	if fm.maxSize <= 0 {
+		fm.maxSize = defaultMirrorMaxSize
+	}
+	if len(fm.types) == 0 {
+		fm.types = defaultMirrorTypes
+	}
+	if len(fm.secret) == 0 {
+		// links will not survive a restart
+		fm.secret = make([]byte, 32)
+		if _, err := rand.Read(fm.secret); err != nil {
+			return nil, err
+		}
+	}
+	return fm, nil
+}
+
+// allowed checks mimetype against the allow-list, which may contain wildcards like "image/*"
+func (fm *fileMirror) allowed(mimetype string) bool {
+	mimetype = strings.ToLower(strings.TrimSpace(strings.SplitN(mimetype, ";", 2)[0]))
+	for _, t := range fm.types {
+		t = strings.ToLower(t)
+		if t == mimetype || (strings.HasSuffix(t, "/*") && strings.HasPrefix(mimetype, t[:len(t)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// claim reports whether the file has not been mirrored to channelID yet and is not being mirrored
+func (fm *fileMirror) claim(fileID, channelID string) bool {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	key := fileID + "/" + channelID
+	if _, ok := fm.mirrored[key]; ok {
+		return false
+	}
+	fm.mirrored[key] = false
+	return true
+}
+
+// finish marks the claimed file as mirrored to channelIDs and stored as sum,
+// or releases it if mirroring failed
+func (fm *fileMirror) finish(fileID string, channelIDs []string, sum string, ok bool) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	for _, channelID := range channelIDs {
+		key := fileID + "/" + channelID
+		if ok {
+			fm.mirrored[key] = true
+			fm.bySum[sum] = append(fm.bySum[sum], key)
+		} else {
+			delete(fm.mirrored, key)
+		}
+	}
+}
+
+// forget removes the files stored as sum from the mirrored files, so they can be mirrored again
+func (fm *fileMirror) forget(sum string) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	for _, key := range fm.bySum[sum] {
+		delete(fm.mirrored, key)
+	}
+	delete(fm.bySum, sum)
+}
+
+// store downloads f into the mirror and returns the hex encoded SHA-256 of its content
+func (fm *fileMirror) store(sc *slack.Client, f *slack.File) (string, error) {
+	if !fm.allowed(f.Mimetype) {
+		return "", fmt.Errorf("type %s is not allowed", f.Mimetype)
+	}
+	tmp, err := ioutil.TempFile(fm.dir, ".download")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	err = sc.DownloadFile(f, io.MultiWriter(tmp, h), fm.maxSize)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+
+	meta, err := json.Marshal(&mirroredFile{Name: f.Name, Mimetype: f.Mimetype})
+	if err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(filepath.Join(fm.dir, sum+".json"), meta, 0600); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(fm.dir, sum)); err != nil {
+		return "", err
+	}
+	return sum, nil
+}
+
+// expire deletes mirrored files stored longer ago than the TTL; links to them have expired
+func (fm *fileMirror) expire() error {
+	infos, err := ioutil.ReadDir(fm.dir)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if info.IsDir() || time.Since(info.ModTime()) <= fm.ttl {
+			continue
+		}
+		if err := os.Remove(filepath.Join(fm.dir, info.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		fm.forget(info.Name())
+	}
+	return nil
+}
+
+// expireMirroredFiles periodically deletes expired mirrored files
+func (bridge *Bridge) expireMirroredFiles() {
+	for {
+		if err := bridge.mirror.expire(); err != nil {
+			bridge.log.Warn("Failed to delete expired mirrored files", "dir", bridge.mirror.dir, "err", err)
+		}
+		time.Sleep(mirrorExpireInterval)
+	}
+}
+
+func (fm *fileMirror) sign(sum, name string, expires int64) string {
+	mac := hmac.New(sha256.New, fm.secret)
+	fmt.Fprintf(mac, "%s\n%s\n%d", sum, name, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// link returns a signed URL for the mirrored file, valid for the configured TTL
+func (fm *fileMirror) link(sum, name string) string {
+	expires := time.Now().Add(fm.ttl).Unix()
+	return fmt.Sprintf("%s/files/%s/%s?exp=%d&sig=%s", fm.baseURL, sum, url.PathEscape(name), expires, fm.sign(sum, name, expires))
+}
+
+var errInvalidLink = errors.New("invalid or expired link")
+
+// verify checks the signature and expiry of a link to /files/<sum>/<name>
+func (fm *fileMirror) verify(r *http.Request) (sum string, err error) {
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/files/"), "/", 2)
+	if len(parts) != 2 {
+		return "", errInvalidLink
+	}
+	sum, name := parts[0], parts[1]
+	if _, err := hex.DecodeString(sum); err != nil || len(sum) != sha256.Size*2 {
+		return "", errInvalidLink
+	}
+	expires, err := strconv.ParseInt(r.URL.Query().Get("exp"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return "", errInvalidLink
+	}
+	if !hmac.Equal([]byte(fm.sign(sum, name, expires)), []byte(r.URL.Query().Get("sig"))) {
+		return "", errInvalidLink
+	}
+	return sum, nil
+}
+
+func (fm *fileMirror) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sum, err := fm.verify(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	data, err := ioutil.ReadFile(filepath.Join(fm.dir, sum+".json"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	var meta mirroredFile
+	if err := json.Unmarshal(data, &meta); err != nil || !fm.allowed(meta.Mimetype) {
+		http.NotFound(w, r)
+		return
+	}
+	f, err := os.Open(filepath.Join(fm.dir, sum))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", meta.Mimetype)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; img-src 'self'; style-src 'unsafe-inline'")
+	disposition := "attachment"
+	if strings.HasPrefix(meta.Mimetype, "image/") {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": path.Base(meta.Name)}))
+	http.ServeContent(w, r, path.Base(meta.Name), info.ModTime(), f)
+}
+
+// FileHandler returns an http.Handler serving mirrored files below /files/,
+// or nil if file mirroring is disabled
+func (bridge *Bridge) FileHandler() http.Handler {
+	if bridge.mirror == nil {
+		return nil
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/files/", bridge.mirror)
+	return mux
+}
+
+// mirrorFile handles file_shared events: it mirrors the file and posts a link to irc.
+// File shares pass the same checks, filter rules and redaction as messages.
+func (bridge *Bridge) mirrorFile(sc *slack.Client, e *slack.Event) {
+	f, err := sc.FileInfo(context.Background(), e.FileID)
+	if err != nil {
+		bridge.log.Warn("Failed to get file info", "side", "slack", "file", e.FileID, "err", err)
+		return
+	}
+	share := &slack.Event{Type: "message", ChannelID: e.ChannelID, UserID: f.UserID, Username: sc.UserName(f.UserID), Text: f.Title}
+	if !bridge.relaySlack(sc, share) {
+		return
+	}
+	var claimed []string
+	for _, channelID := range append(f.Channels, f.Groups...) {
+		if sc.ChannelName(channelID) == bridge.slackChan() && bridge.mirror.claim(f.ID, channelID) {
+			claimed = append(claimed, channelID)
+		}
+	}
+	if len(claimed) == 0 {
+		return
+	}
+	description := f.Name
+	if f.Title != "" && f.Title != f.Name {
+		description = fmt.Sprintf("%s (%s)", f.Title, f.Name)
+	}
+	text, ok := bridge.filterSlack(share, "has shared a file: "+description)
+	if !ok {
+		bridge.mirror.finish(f.ID, claimed, "", false)
+		return
+	}
+	sum, err := bridge.mirror.store(sc, f)
+	bridge.mirror.finish(f.ID, claimed, sum, err == nil)
+	if err != nil {
+		bridge.log.Warn("Failed to mirror file", "side", "slack", "channel", bridge.slackChan(), "file", f.ID, "err", err)
+		return
+	}
+	// the link carries the name as well
+	name := f.Name
+	if bridge.redactor != nil {
+		name, _ = bridge.redactor.redact(name)
+	}
+	bridge.sendSlackToIRC(share, text+" "+bridge.mirror.link(sum, name))
+}
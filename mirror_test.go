@@ -0,0 +1,168 @@
+package slirc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/simonkern/slirc/slack"
+)
+
+func TestFileMirror(t *testing.T) {
+	content := "hello from slack"
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer xoxb-test" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(content))
+	}))
+	defer files.Close()
+
+	dir, err := ioutil.TempDir("", "slirc-mirror")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fm, err := newFileMirror(&Config{FileMirrorDir: dir, FileMirrorURL: "https://files.example.org/", FileMirrorTypes: []string{"image/*", "text/plain"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := slack.NewClient("xoxb-test")
+
+	if _, err := fm.store(sc, &slack.File{ID: "F1", Name: "evil.html", Mimetype: "text/html", URLPrivate: files.URL}); err == nil {
+		t.Error("store accepted a type that is not allowed")
+	}
+	fm.maxSize = 4
+	if _, err := fm.store(sc, &slack.File{ID: "F1", Name: "big.txt", Mimetype: "text/plain", URLPrivate: files.URL}); err != slack.ErrFileTooLarge {
+		t.Errorf("store - expected ErrFileTooLarge - got: (%v)", err)
+	}
+	fm.maxSize = defaultMirrorMaxSize
+
+	sum, err := fm.store(sc, &slack.File{ID: "F1", Name: "hello world.txt", Mimetype: "text/plain; charset=utf-8", URLPrivate: files.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := fm.link(sum, "hello world.txt")
+	if !strings.HasPrefix(link, "https://files.example.org/files/"+sum+"/hello%20world.txt?exp=") {
+		t.Errorf("unexpected link: %v", link)
+	}
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		fm.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		return rec
+	}
+	path := strings.TrimPrefix(link, "https://files.example.org")
+	rec := get(path)
+	if rec.Code != http.StatusOK || rec.Body.String() != content {
+		t.Errorf("GET %s - got: (%v) %q", path, rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("X-Content-Type-Options") != "nosniff" || !strings.HasPrefix(rec.Header().Get("Content-Disposition"), "attachment") {
+		t.Errorf("missing security headers: %v", rec.Header())
+	}
+
+	// flip the last hex digit of the signature
+	last := "0"
+	if strings.HasSuffix(path, "0") {
+		last = "1"
+	}
+	tests := []struct {
+		name   string
+		target string
+	}{
+		{"tampered signature", path[:len(path)-1] + last},
+		{"other name", strings.Replace(path, "hello%20world.txt", "other.txt", 1)},
+		{"no signature", "/files/" + sum + "/hello%20world.txt"},
+	}
+	for _, tt := range tests {
+		if rec := get(tt.target); rec.Code != http.StatusForbidden {
+			t.Errorf("%s - expected 403 - got: (%v)", tt.name, rec.Code)
+		}
+	}
+
+	fm.ttl = -time.Minute
+	if rec := get(strings.TrimPrefix(fm.link(sum, "hello world.txt"), "https://files.example.org")); rec.Code != http.StatusForbidden {
+		t.Errorf("expired link - expected 403 - got: (%v)", rec.Code)
+	}
+
+	if !fm.claim("F1", "C1") || fm.claim("F1", "C1") || !fm.claim("F1", "C2") {
+		t.Error("claim does not deduplicate by file and channel")
+	}
+	fm.finish("F1", []string{"C1"}, sum, true)
+	fm.finish("F1", []string{"C2"}, "", false)
+	if fm.claim("F1", "C1") || !fm.claim("F1", "C2") {
+		t.Error("a file that failed to mirror cannot be mirrored again")
+	}
+
+	// files stored longer ago than the TTL are deleted
+	fm.ttl = time.Hour
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, sum), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := fm.expire(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, sum)); !os.IsNotExist(err) {
+		t.Errorf("expired file has not been deleted: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, sum+".json")); err != nil {
+		t.Errorf("file within the TTL has been deleted: %v", err)
+	}
+	if !fm.claim("F1", "C1") {
+		t.Error("an expired file cannot be mirrored again")
+	}
+	if len(fm.bySum) != 0 {
+		t.Errorf("expired file is still tracked: %v", fm.bySum)
+	}
+}
+
+func TestMirrorFileFilters(t *testing.T) {
+	var downloads int32
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/files.info":
+			fmt.Fprintf(w, `{"ok":true,"file":{"id":%q,"user":"U1","name":"cat.png","mimetype":"image/png","size":3,"url_private_download":%q,"channels":["C1"]}}`,
+				r.FormValue("file"), srv.URL+"/download")
+		case "/download":
+			atomic.AddInt32(&downloads, 1)
+			w.Write([]byte("cat"))
+		}
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "slirc-mirror")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fm, err := newFileMirror(&Config{FileMirrorDir: dir, FileMirrorURL: "https://files.example.org/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := slack.NewClient("xoxb-test")
+	sc.APIURL = srv.URL + "/"
+	filters, err := LoadFilters("", []FilterRule{{UserID: "U1", Action: FilterDrop}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bridge := &Bridge{SlackChan: "C1", slack: sc, log: slack.NewStdLogger(nil, false), mirror: fm, filters: filters}
+
+	bridge.mirrorFile(sc, &slack.Event{Type: "file_shared", FileID: "F1"})
+	if n := atomic.LoadInt32(&downloads); n != 0 {
+		t.Errorf("file dropped by a filter rule has been downloaded %d times", n)
+	}
+	if !fm.claim("F1", "C1") {
+		t.Error("file dropped by a filter rule has been marked as mirrored")
+	}
+}
@@ -0,0 +1,186 @@
+package slirc
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/simonkern/slirc/slack"
+)
+
+// defaultPrivateTimeout is used if Config.PrivateTimeout is not set
+const defaultPrivateTimeout = 30 * time.Minute
+
+// privateRoute is an active private conversation between an irc user and a slack user
+type privateRoute struct {
+	ircNick    string
+	slackUser  string
+	lastActive time.Time
+}
+
+// routeKey identifies a conversation by the lowercased irc nick and the slack user ID
+type routeKey struct {
+	nick string
+	user string
+}
+
+// privateRoutes tracks private conversations. An irc nick or a slack user may take
+// part in several conversations; unaddressed messages go to the most recent one.
+type privateRoutes struct {
+	ttl time.Duration
+
+	mu     sync.Mutex
+	routes map[routeKey]*privateRoute
+}
+
+func newPrivateRoutes(ttl time.Duration) *privateRoutes {
+	if ttl <= 0 {
+		ttl = defaultPrivateTimeout
+	}
+	return &privateRoutes{
+		ttl:    ttl,
+		routes: make(map[routeKey]*privateRoute),
+	}
+}
+
+// open starts or continues the conversation between nick and userID
+func (pr *privateRoutes) open(nick, userID string) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	key := routeKey{strings.ToLower(nick), userID}
+	if route, ok := pr.routes[key]; ok {
+		route.ircNick = nick
+		route.lastActive = time.Now()
+		return
+	}
+	pr.routes[key] = &privateRoute{ircNick: nick, slackUser: userID, lastActive: time.Now()}
+}
+
+// has reports whether nick and userID have an open conversation
+func (pr *privateRoutes) has(nick, userID string) bool {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	return pr.active(routeKey{strings.ToLower(nick), userID}) != nil
+}
+
+// forNick returns the slack user nick talked to most recently
+func (pr *privateRoutes) forNick(nick string) (userID string, ok bool) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	route := pr.latest(func(key routeKey) bool { return key.nick == strings.ToLower(nick) })
+	if route == nil {
+		return "", false
+	}
+	return route.slackUser, true
+}
+
+// forUser returns the irc nick the slack user talked to most recently
+func (pr *privateRoutes) forUser(userID string) (nick string, ok bool) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	route := pr.latest(func(key routeKey) bool { return key.user == userID })
+	if route == nil {
+		return "", false
+	}
+	return route.ircNick, true
+}
+
+// latest returns the most recently used active route matching match and marks it as used;
+// the caller must hold the lock
+func (pr *privateRoutes) latest(match func(routeKey) bool) *privateRoute {
+	var route *privateRoute
+	for key, r := range pr.routes {
+		if !match(key) {
+			continue
+		}
+		if time.Since(r.lastActive) > pr.ttl {
+			delete(pr.routes, key)
+			continue
+		}
+		if route == nil || r.lastActive.After(route.lastActive) {
+			route = r
+		}
+	}
+	if route != nil {
+		route.lastActive = time.Now()
+	}
+	return route
+}
+
+// active returns the route of key if it has not timed out and marks it as used; the caller must hold the lock
+func (pr *privateRoutes) active(key routeKey) *privateRoute {
+	route, ok := pr.routes[key]
+	if !ok {
+		return nil
+	}
+	if time.Since(route.lastActive) > pr.ttl {
+		delete(pr.routes, key)
+		return nil
+	}
+	route.lastActive = time.Now()
+	return route
+}
+
+// splitRecipient splits "name: text" into name and text
+func splitRecipient(text string) (name, rest string, ok bool) {
+	i := strings.Index(text, ":")
+	if i < 1 || strings.ContainsAny(text[:i], " \t") {
+		return "", text, false
+	}
+	return text[:i], strings.TrimSpace(text[i+1:]), true
+}
+
+// slackUserFor resolves a slack user name, display name or linked irc nick to a slack user ID
+func (bridge *Bridge) slackUserFor(name string) (userID string, ok bool) {
+	if userID, ok = bridge.slack.UserIDByName(name); ok {
+		return userID, true
+	}
+	return bridge.identities.SlackUser(name)
+}
+
+// handleIRCQuery relays a private irc message to the addressed slack user
+func (bridge *Bridge) handleIRCQuery(nick, text string) {
+	userID, ok := bridge.private.forNick(nick)
+	if name, rest, prefixed := splitRecipient(text); prefixed {
+		if id, found := bridge.slackUserFor(name); found {
+			userID, ok, text = id, true, rest
+		}
+	}
+	if !ok {
+		bridge.irc.Privmsg(nick, "Usage: /msg "+bridge.irc.Me().Nick+" <slackuser>: <message>")
+		return
+	}
+	if text == "" {
+		return
+	}
+	bridge.private.open(nick, userID)
+	text = bridge.redactIRC(text)
+	if err := bridge.slack.SendIM(userID, fmt.Sprintf("[%s]: %s", nick, text)); err != nil {
+		bridge.log.Warn("Failed to relay private message", "side", "slack", "user", userID, "err", err)
+		bridge.irc.Privmsg(nick, "Could not deliver your message to "+bridge.slack.UserName(userID)+".")
+	}
+}
+
+// handleSlackIM relays a direct message to the bot to an irc user the slack user is talking to.
+// Slack users can only write to nicks that have written to them first.
+func (bridge *Bridge) handleSlackIM(e *slack.Event) {
+	nick, ok := bridge.private.forUser(e.UserID)
+	text := e.Msg()
+	if name, rest, prefixed := splitRecipient(text); prefixed && bridge.private.has(name, e.UserID) {
+		nick, ok, text = name, true, rest
+	}
+	if !ok {
+		bridge.slack.SendIM(e.UserID, "You can only reply to IRC users who have written to you with /msg "+bridge.irc.Me().Nick+" <slackuser>: <message>.")
+		return
+	}
+	if text == "" {
+		return
+	}
+	bridge.private.open(nick, e.UserID)
+	text = bridge.redactSlack(e.UserID, text)
+	msg := fmt.Sprintf("[%s]: %s", e.Usernick(), text)
+	for _, line := range splitLines(msg) {
+		bridge.ircPrivmsg(nick, line)
+	}
+}
@@ -0,0 +1,56 @@
+package slirc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPrivateRoutes(t *testing.T) {
+	pr := newPrivateRoutes(time.Minute)
+
+	pr.open("jdoe", "U11A2B8C1")
+	if userID, ok := pr.forNick("JDoe"); !ok || userID != "U11A2B8C1" {
+		t.Errorf("forNick - expected: (U11A2B8C1) - got: (%v)", userID)
+	}
+
+	// a slack user can talk to several nicks; replies go to the most recent one
+	time.Sleep(time.Millisecond)
+	pr.open("alice", "U11A2B8C1")
+	if !pr.has("JDOE", "U11A2B8C1") || !pr.has("alice", "U11A2B8C1") {
+		t.Error("expected both conversations to be open")
+	}
+	if pr.has("alice", "U22B3C9D2") {
+		t.Error("unexpected conversation of alice and U22B3C9D2")
+	}
+	if nick, ok := pr.forUser("U11A2B8C1"); !ok || nick != "alice" {
+		t.Errorf("forUser - expected: (alice) - got: (%v)", nick)
+	}
+
+	// conversations time out
+	pr.routes[routeKey{"alice", "U11A2B8C1"}].lastActive = time.Now().Add(-2 * time.Minute)
+	if nick, ok := pr.forUser("U11A2B8C1"); !ok || nick != "jdoe" {
+		t.Errorf("forUser after timeout - expected: (jdoe) - got: (%v)", nick)
+	}
+	if _, ok := pr.forNick("alice"); ok {
+		t.Error("timed out conversation is still reachable by nick")
+	}
+}
+
+func TestSplitRecipient(t *testing.T) {
+	cases := []struct {
+		text, name, rest string
+		ok               bool
+	}{
+		{"alice: hi there", "alice", "hi there", true},
+		{"alice:hi", "alice", "hi", true},
+		{"see: http://example.com", "see", "http://example.com", true},
+		{"hi alice: there", "", "hi alice: there", false},
+		{": hi", "", ": hi", false},
+	}
+	for _, c := range cases {
+		name, rest, ok := splitRecipient(c.text)
+		if name != c.name || rest != c.rest || ok != c.ok {
+			t.Errorf("splitRecipient(%q) - expected: (%q, %q, %v) - got: (%q, %q, %v)", c.text, c.name, c.rest, c.ok, name, rest, ok)
+		}
+	}
+}
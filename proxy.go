@@ -0,0 +1,108 @@
+package slirc
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"golang.org/x/net/proxy"
+)
+
+var registerHTTPProxy sync.Once
+
+// ircProxy returns the proxy URL used for irc connections
+func (c *Config) ircProxy() string {
+	if c.IRCProxy != "" {
+		return c.IRCProxy
+	}
+	return c.Proxy
+}
+
+// slackProxy returns the proxy URL used for slack connections
+func (c *Config) slackProxy() string {
+	if c.SlackProxy != "" {
+		return c.SlackProxy
+	}
+	return c.Proxy
+}
+
+// validateProxy checks that proxyURL is a proxy URL slirc can dial through
+func validateProxy(proxyURL string) error {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "socks5", "socks5h", "http":
+	default:
+		return fmt.Errorf("unsupported scheme %q, use socks5 or http", u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("missing host in %q", proxyURL)
+	}
+	return nil
+}
+
+// enableHTTPProxy teaches golang.org/x/net/proxy, which the irc client dials
+// through, to use HTTP CONNECT proxies. It only supports SOCKS5 on its own.
+func enableHTTPProxy() {
+	registerHTTPProxy.Do(func() {
+		proxy.RegisterDialerType("http", func(u *url.URL, forward proxy.Dialer) (proxy.Dialer, error) {
+			return &httpConnectDialer{proxy: u, forward: forward}, nil
+		})
+	})
+}
+
+// httpConnectDialer tunnels connections through an HTTP proxy using CONNECT
+type httpConnectDialer struct {
+	proxy   *url.URL
+	forward proxy.Dialer
+}
+
+func (hd *httpConnectDialer) Dial(network, addr string) (net.Conn, error) {
+	conn, err := hd.forward.Dial("tcp", hd.proxy.Host)
+	if err != nil {
+		return nil, err
+	}
+	req := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if user := hd.proxy.User; user != nil {
+		password, _ := user.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		conn.Close()
+		return nil, fmt.Errorf("proxy refused CONNECT to %s: %s", addr, resp.Status)
+	}
+	// irc servers talk first, keep what has already been buffered
+	return &bufferedConn{Conn: conn, r: br}, nil
+}
+
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (bc *bufferedConn) Read(b []byte) (int, error) {
+	return bc.r.Read(b)
+}
@@ -0,0 +1,94 @@
+package slirc
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"golang.org/x/net/proxy"
+)
+
+func TestHTTPConnectDialer(t *testing.T) {
+	// an irc server that talks first
+	ircd, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ircd.Close()
+	go func() {
+		conn, err := ircd.Accept()
+		if err != nil {
+			return
+		}
+		conn.Write([]byte("NOTICE * :*** Looking up your hostname...\r\n"))
+		conn.Close()
+	}()
+
+	connectProxy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connectProxy.Close()
+	auth := make(chan string, 1)
+	go func() {
+		conn, err := connectProxy.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil || req.Method != "CONNECT" {
+			return
+		}
+		auth <- req.Header.Get("Proxy-Authorization")
+		target, err := net.Dial("tcp", req.Host)
+		if err != nil {
+			conn.Write([]byte("HTTP/1.1 502 Bad Gateway\r\n\r\n"))
+			return
+		}
+		defer target.Close()
+		conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		io.Copy(conn, target)
+	}()
+
+	enableHTTPProxy()
+	u, _ := url.Parse("http://slirc:hunter2@" + connectProxy.Addr().String())
+	dialer, err := proxy.FromURL(u, proxy.Direct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := dialer.Dial("tcp", ircd.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "NOTICE * :*** Looking up your hostname...\r\n" {
+		t.Errorf("unexpected line through proxy: %q", line)
+	}
+	if gotAuth := <-auth; gotAuth != "Basic c2xpcmM6aHVudGVyMg==" {
+		t.Errorf("Proxy-Authorization - expected: (Basic c2xpcmM6aHVudGVyMg==) - got: (%v)", gotAuth)
+	}
+}
+
+func TestProxyConfig(t *testing.T) {
+	c := &Config{Proxy: "socks5://127.0.0.1:1080", SlackProxy: "http://proxy:3128"}
+	if c.ircProxy() != "socks5://127.0.0.1:1080" || c.slackProxy() != "http://proxy:3128" {
+		t.Errorf("per-side override failed: %v %v", c.ircProxy(), c.slackProxy())
+	}
+	for _, invalid := range []string{"ftp://proxy:21", "https://proxy:3128"} {
+		if err := validateProxy(invalid); err == nil {
+			t.Errorf("unsupported scheme accepted: %q", invalid)
+		}
+	}
+	if err := validateProxy("socks5://"); err == nil {
+		t.Error("missing host accepted")
+	}
+}
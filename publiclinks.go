@@ -0,0 +1,201 @@
+package slirc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/simonkern/slirc/slack"
+)
+
+// publicLinkCheckInterval is the time between two runs of the revocation job
+const publicLinkCheckInterval = 10 * time.Minute
+
+// PublicLink is a slack file the bridge made public using the user token
+type PublicLink struct {
+	FileID    string    `json:"file_id"`
+	Name      string    `json:"name,omitempty"`
+	UserID    string    `json:"user_id,omitempty"`
+	URL       string    `json:"url,omitempty"`
+	Published time.Time `json:"published"`
+}
+
+// PublicLinks is the persisted record of the files the bridge made public
+type PublicLinks struct {
+	file string
+
+	mu    sync.Mutex
+	links map[string]*PublicLink // by file ID
+}
+
+// LoadPublicLinks reads the record from file. A missing file yields an empty record.
+// If file is empty, the record is kept in memory only.
+func LoadPublicLinks(file string) (*PublicLinks, error) {
+	pl := &PublicLinks{file: file, links: make(map[string]*PublicLink)}
+	if file == "" {
+		return pl, nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return pl, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var links []*PublicLink
+	if err := json.Unmarshal(data, &links); err != nil {
+		return nil, fmt.Errorf("Failed to parse public link file %s: %v", file, err)
+	}
+	for _, link := range links {
+		pl.links[link.FileID] = link
+	}
+	return pl, nil
+}
+
+// Add records link and persists the record
+func (pl *PublicLinks) Add(link *PublicLink) error {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	pl.links[link.FileID] = link
+	return pl.save()
+}
+
+// Remove forgets the link of fileID and persists the record
+func (pl *PublicLinks) Remove(fileID string) error {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	delete(pl.links, fileID)
+	return pl.save()
+}
+
+// Get returns the link of fileID
+func (pl *PublicLinks) Get(fileID string) (link PublicLink, ok bool) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	if l, ok := pl.links[fileID]; ok {
+		return *l, true
+	}
+	return link, false
+}
+
+// Links returns all links, oldest first
+func (pl *PublicLinks) Links() []PublicLink {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	links := make([]PublicLink, 0, len(pl.links))
+	for _, link := range pl.links {
+		links = append(links, *link)
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].Published.Before(links[j].Published) })
+	return links
+}
+
+// Expired returns the links published more than ttl ago
+func (pl *PublicLinks) Expired(ttl time.Duration) (expired []PublicLink) {
+	for _, link := range pl.Links() {
+		if time.Since(link.Published) > ttl {
+			expired = append(expired, link)
+		}
+	}
+	return expired
+}
+
+// save writes the record atomically; the caller must hold the lock
+func (pl *PublicLinks) save() error {
+	if pl.file == "" {
+		return nil
+	}
+	links := make([]*PublicLink, 0, len(pl.links))
+	for _, link := range pl.links {
+		links = append(links, link)
+	}
+	data, err := json.MarshalIndent(links, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(pl.file), ".publiclinks")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), pl.file)
+}
+
+// recordPublicLink is called by the slack client for every file it made public
+func (bridge *Bridge) recordPublicLink(f *slack.File) {
+	link := &PublicLink{FileID: f.ID, Name: f.Name, UserID: f.UserID, URL: f.PubPerma, Published: time.Now()}
+	if err := bridge.publicLinks.Add(link); err != nil {
+		bridge.log.Error("Failed to save public links", "side", "slack", "file", f.ID, "err", err)
+	}
+}
+
+// revokePublicLink disables the public link of fileID and forgets it
+func (bridge *Bridge) revokePublicLink(fileID string) error {
+	err := bridge.slack.RevokePublicURL(fileID)
+	switch {
+	case slack.IsAPIError(err, "file_not_found") || slack.IsAPIError(err, "file_deleted"):
+		// the link is gone with the file
+		bridge.log.Info("Forgetting public link of deleted file", "side", "slack", "file", fileID)
+	case err != nil:
+		return err
+	default:
+		bridge.log.Info("Revoked public file link", "side", "slack", "file", fileID)
+	}
+	return bridge.publicLinks.Remove(fileID)
+}
+
+// revokeExpiredLinks periodically revokes public links older than ttl
+func (bridge *Bridge) revokeExpiredLinks(ttl time.Duration) {
+	for {
+		for _, link := range bridge.publicLinks.Expired(ttl) {
+			if err := bridge.revokePublicLink(link.FileID); err != nil {
+				bridge.log.Warn("Failed to revoke public file link", "side", "slack", "file", link.FileID, "err", err)
+			}
+		}
+		time.Sleep(publicLinkCheckInterval)
+	}
+}
+
+// handlePublicLinkCommand handles the admin commands "publiclinks list" and "publiclinks revoke <fileid>"
+func (bridge *Bridge) handlePublicLinkCommand(text string, admin bool) (reply string, ok bool) {
+	args, ok := parseCommand(text, "publiclinks")
+	if !ok || !admin {
+		return "", false
+	}
+	fields := strings.Fields(args)
+	switch {
+	case len(fields) == 1 && fields[0] == "list":
+		var lines []string
+		for _, link := range bridge.publicLinks.Links() {
+			lines = append(lines, fmt.Sprintf("%s %s %q by %s since %s", link.FileID, link.URL, link.Name,
+				bridge.slack.UserName(link.UserID), link.Published.Format("2006-01-02 15:04")))
+		}
+		if len(lines) == 0 {
+			return "No public links.", true
+		}
+		return strings.Join(lines, "\n"), true
+	case len(fields) == 2 && fields[0] == "revoke":
+		if _, ok := bridge.publicLinks.Get(fields[1]); !ok {
+			return fmt.Sprintf("%s has not been made public by the bridge.", fields[1]), true
+		}
+		if err := bridge.revokePublicLink(fields[1]); err != nil {
+			return fmt.Sprintf("Failed to revoke %s: %v", fields[1], err), true
+		}
+		return fmt.Sprintf("Revoked the public link of %s.", fields[1]), true
+	}
+	return "Usage: publiclinks list | publiclinks revoke <fileid>", true
+}
@@ -0,0 +1,105 @@
+package slirc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/simonkern/slirc/slack"
+)
+
+func TestPublicLinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "slirc-publiclinks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "publiclinks.json")
+
+	pl, err := LoadPublicLinks(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	if err := pl.Add(&PublicLink{FileID: "F1", Name: "old.png", Published: old}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pl.Add(&PublicLink{FileID: "F2", Name: "new.png", Published: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	// survives a restart
+	pl, err = LoadPublicLinks(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if links := pl.Links(); len(links) != 2 || links[0].FileID != "F1" {
+		t.Errorf("Links - expected F1 and F2, oldest first - got: (%+v)", links)
+	}
+	if expired := pl.Expired(24 * time.Hour); len(expired) != 1 || expired[0].FileID != "F1" {
+		t.Errorf("Expired - expected F1 - got: (%+v)", expired)
+	}
+}
+
+func TestPublicLinkCommand(t *testing.T) {
+	var mu sync.Mutex
+	var revoked []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/files.revokePublicURL" || r.Header.Get("Authorization") != "Bearer xoxp-test" {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) == "file=F2" {
+			fmt.Fprint(w, `{"ok":false,"error":"file_not_found"}`)
+			return
+		}
+		mu.Lock()
+		revoked = append(revoked, string(body))
+		mu.Unlock()
+		fmt.Fprint(w, `{"ok":true,"file":{"id":"F1"}}`)
+	}))
+	defer api.Close()
+
+	sc := slack.NewClient("xoxb-test")
+	sc.UserToken = "xoxp-test"
+	sc.APIURL = api.URL + "/"
+	publicLinks, _ := LoadPublicLinks("")
+	bridge := &Bridge{slack: sc, log: slack.NewStdLogger(nil, false), publicLinks: publicLinks}
+
+	bridge.recordPublicLink(&slack.File{ID: "F1", Name: "cat.png", UserID: "U1", PubPerma: "https://slack-files.com/T1-F1-abc"})
+
+	if _, ok := bridge.handlePublicLinkCommand("publiclinks list", false); ok {
+		t.Error("publiclinks is available to non-admins")
+	}
+	if reply, _ := bridge.handlePublicLinkCommand("publiclinks list", true); !strings.Contains(reply, "https://slack-files.com/T1-F1-abc") {
+		t.Errorf("publiclinks list - got: (%v)", reply)
+	}
+	if reply, _ := bridge.handlePublicLinkCommand("publiclinks revoke F9", true); !strings.Contains(reply, "not been made public") {
+		t.Errorf("publiclinks revoke F9 - got: (%v)", reply)
+	}
+	if reply, _ := bridge.handlePublicLinkCommand("publiclinks revoke F1", true); !strings.HasPrefix(reply, "Revoked") {
+		t.Errorf("publiclinks revoke F1 - got: (%v)", reply)
+	}
+	mu.Lock()
+	if len(revoked) != 1 || revoked[0] != "file=F1" {
+		t.Errorf("expected one files.revokePublicURL call for F1 - got: (%v)", revoked)
+	}
+	mu.Unlock()
+
+	// links of deleted files are forgotten instead of being retried forever
+	bridge.recordPublicLink(&slack.File{ID: "F2", Name: "gone.png", UserID: "U1", PubPerma: "https://slack-files.com/T1-F2-abc"})
+	if err := bridge.revokePublicLink("F2"); err != nil {
+		t.Errorf("revokePublicLink of a deleted file - got: %v", err)
+	}
+	if reply, _ := bridge.handlePublicLinkCommand("publiclinks list", true); reply != "No public links." {
+		t.Errorf("publiclinks list after revoke - got: (%v)", reply)
+	}
+}
@@ -0,0 +1,245 @@
+package slirc
+
+import (
+	"crypto/tls"
+	"strings"
+	"sync"
+	"time"
+
+	ircc "github.com/fluffle/goirc/client"
+)
+
+const (
+	// defaultPuppetIdle is used if Config.IRCPuppetIdle is not set
+	defaultPuppetIdle = 30 * time.Minute
+	// defaultPuppetSuffix is used if Config.IRCPuppetSuffix is not set
+	defaultPuppetSuffix = "[s]"
+	// puppetJoinTimeout is the time a puppet has to connect and join the channel
+	puppetJoinTimeout = time.Minute
+	// puppetQuitTimeout is the time a quitting puppet waits for the server to close the connection
+	puppetQuitTimeout = 5 * time.Second
+	// puppetQueueLen is the number of lines queued per puppet before falling back to the bot
+	puppetQueueLen = 64
+	// maxNickLen is a conservative NICKLEN most networks support
+	maxNickLen = 16
+)
+
+// puppet is an irc connection relaying the messages of a single slack user
+type puppet struct {
+	userID string
+	conn   *ircc.Conn
+	lines  chan string
+	joined chan struct{}
+	done   chan struct{}
+	once   sync.Once
+	// connectDone is closed once Connect has returned
+	connectDone chan struct{}
+}
+
+func (p *puppet) stop() {
+	p.once.Do(func() { close(p.done) })
+}
+
+// puppets manages one irc connection per active slack user
+type puppets struct {
+	channel string
+	idle    time.Duration
+	max     int
+	suffix  string
+	// newConn returns an unconnected irc connection using nick
+	newConn func(nick string) *ircc.Conn
+	// fallback relays a line through the bridge's own connection
+	fallback func(name, line string)
+
+	mu     sync.Mutex
+	byUser map[string]*puppet
+}
+
+func newPuppets(c *Config, tlsCfg *tls.Config, fallback func(name, line string)) *puppets {
+	pp := &puppets{
+		channel:  c.IRCChan,
+		idle:     c.IRCPuppetIdle,
+		max:      c.IRCPuppetMax,
+		suffix:   c.IRCPuppetSuffix,
+		fallback: fallback,
+		byUser:   make(map[string]*puppet),
+	}
+	if pp.idle <= 0 {
+		pp.idle = defaultPuppetIdle
+	}
+	if pp.suffix == "" {
+		pp.suffix = defaultPuppetSuffix
+	}
+	if tlsCfg != nil {
+		// puppets must not log in to the bot's services account with its client certificate
+		tlsCfg = tlsCfg.Clone()
+		tlsCfg.Certificates = nil
+	}
+	pp.newConn = func(nick string) *ircc.Conn {
+		ircCfg := newIRCConfig(c, nick, tlsCfg)
+		ircCfg.QuitMessage = "Idle"
+		return ircc.Client(ircCfg)
+	}
+	return pp
+}
+
+// sanitizeNick turns a slack name into a valid irc nick ending in suffix
+func sanitizeNick(name, suffix string) string {
+	var nick []rune
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', strings.ContainsRune("_-\\^{}|`", r):
+			nick = append(nick, r)
+		}
+	}
+	// nicks must not start with a digit or a dash
+	for len(nick) > 0 && (nick[0] == '-' || (nick[0] >= '0' && nick[0] <= '9')) {
+		nick = nick[1:]
+	}
+	if len(nick) == 0 {
+		nick = []rune("slack")
+	}
+	if max := maxNickLen - len(suffix); len(nick) > max && max > 0 {
+		nick = nick[:max]
+	}
+	return string(nick) + suffix
+}
+
+// send relays lines of the slack user through the user's puppet, connecting it if necessary.
+// It returns false if no puppet is available, e.g. because the connection limit has been reached
+// or the message has been posted by a bot or integration, which has no user ID.
+func (pp *puppets) send(userID, name string, lines []string) bool {
+	if userID == "" {
+		return false
+	}
+	pp.mu.Lock()
+	p, ok := pp.byUser[userID]
+	if !ok {
+		if pp.max > 0 && len(pp.byUser) >= pp.max {
+			pp.mu.Unlock()
+			return false
+		}
+		p = pp.start(userID, name)
+	}
+	queued := 0
+	for _, line := range lines {
+		select {
+		case p.lines <- line:
+			queued++
+			continue
+		default:
+		}
+		break
+	}
+	pp.mu.Unlock()
+
+	// queue is full, relay the rest through the bot
+	for _, line := range lines[queued:] {
+		pp.fallback(name, line)
+	}
+	return true
+}
+
+// isPuppet reports whether nick belongs to one of our puppets
+func (pp *puppets) isPuppet(nick string) bool {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	for _, p := range pp.byUser {
+		if p.conn.Me().Nick == nick {
+			return true
+		}
+	}
+	return false
+}
+
+// start creates and connects a puppet; the caller must hold the lock
+func (pp *puppets) start(userID, name string) *puppet {
+	p := &puppet{
+		userID: userID,
+		conn:   pp.newConn(sanitizeNick(name, pp.suffix)),
+		lines:  make(chan string, puppetQueueLen),
+		joined: make(chan struct{}),
+		done:   make(chan struct{}),
+
+		connectDone: make(chan struct{}),
+	}
+	var joinOnce sync.Once
+	p.conn.HandleFunc(ircc.CONNECTED,
+		func(conn *ircc.Conn, line *ircc.Line) {
+			conn.Join(pp.channel)
+		})
+	p.conn.HandleFunc(ircc.JOIN,
+		func(conn *ircc.Conn, line *ircc.Line) {
+			if line.Nick == conn.Me().Nick && line.Target() == pp.channel {
+				joinOnce.Do(func() { close(p.joined) })
+			}
+		})
+	p.conn.HandleFunc(ircc.DISCONNECTED,
+		func(conn *ircc.Conn, line *ircc.Line) {
+			p.stop()
+		})
+	pp.byUser[userID] = p
+
+	go func() {
+		defer close(p.connectDone)
+		if err := p.conn.Connect(); err != nil {
+			p.stop()
+		}
+	}()
+	go pp.run(p, name)
+	return p
+}
+
+// run relays the lines of p until it has been idle for too long or got disconnected
+func (pp *puppets) run(p *puppet, name string) {
+	defer pp.remove(p, name)
+
+	select {
+	case <-p.joined:
+	case <-p.done:
+		return
+	case <-time.After(puppetJoinTimeout):
+		return
+	}
+
+	for {
+		select {
+		case line := <-p.lines:
+			p.conn.Privmsg(pp.channel, line)
+		case <-time.After(pp.idle):
+			return
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// remove disconnects p and relays lines it could not send through the bot
+func (pp *puppets) remove(p *puppet, name string) {
+	pp.mu.Lock()
+	if pp.byUser[p.userID] == p {
+		delete(pp.byUser, p.userID)
+	}
+	pp.mu.Unlock()
+
+	// no one sends to p anymore, drain its queue
+	for {
+		select {
+		case line := <-p.lines:
+			pp.fallback(name, line)
+		default:
+			// the join may have timed out while Connect is still running
+			<-p.connectDone
+			if p.conn.Connected() {
+				p.conn.Quit()
+				select {
+				case <-p.done:
+				case <-time.After(puppetQuitTimeout):
+				}
+			}
+			p.conn.Close()
+			p.stop()
+			return
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package slirc
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"testing"
+	"time"
+
+	ircc "github.com/fluffle/goirc/client"
+
+	"github.com/simonkern/slirc/slack"
+)
+
+func TestSanitizeNick(t *testing.T) {
+	cases := map[string]string{
+		"alice":                 "alice[s]",
+		"Jörg Müller":           "JrgMller[s]",
+		"42-bob":                "bob[s]",
+		"äöü":                   "slack[s]",
+		"averyveryverylongname": "averyveryvery[s]",
+		"under_score|pipe":      "under_score|p[s]",
+	}
+	for name, want := range cases {
+		if got := sanitizeNick(name, "[s]"); got != want {
+			t.Errorf("sanitizeNick(%q) - expected: (%v) - got: (%v)", name, want, got)
+		}
+	}
+}
+
+func TestPuppetLimit(t *testing.T) {
+	pp := newPuppets(&Config{IRCChan: "#slirctest", IRCPuppetMax: 1}, nil, func(name, line string) {})
+	pp.byUser["U11A2B8C1"] = &puppet{userID: "U11A2B8C1", lines: make(chan string, puppetQueueLen)}
+
+	if pp.send("U11A2BBCK", "testorizor2", []string{"hello"}) {
+		t.Error("send started a puppet beyond IRCPuppetMax")
+	}
+	if !pp.send("U11A2B8C1", "testorizor1", []string{"hello", "world"}) {
+		t.Error("send did not use the existing puppet")
+	}
+	if len(pp.byUser["U11A2B8C1"].lines) != 2 {
+		t.Errorf("expected 2 queued lines, got %v", len(pp.byUser["U11A2B8C1"].lines))
+	}
+}
+
+func TestPuppetFallback(t *testing.T) {
+	var pp *puppets
+	var relayed []string
+	pp = newPuppets(&Config{IRCChan: "#slirctest"}, nil, func(name, line string) {
+		// fallback must be called without holding the lock
+		pp.mu.Lock()
+		relayed = append(relayed, line)
+		pp.mu.Unlock()
+	})
+	pp.byUser["U11A2B8C1"] = &puppet{userID: "U11A2B8C1", lines: make(chan string, 1)}
+
+	done := make(chan struct{})
+	go func() {
+		pp.send("U11A2B8C1", "testorizor1", []string{"hello", "world", "!"})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("send deadlocked relaying through the bot")
+	}
+	if len(relayed) != 2 || relayed[0] != "world" {
+		t.Errorf("expected the lines beyond the queue to be relayed by the bot - got: %v", relayed)
+	}
+}
+
+func TestPuppetTLSConfig(t *testing.T) {
+	tlsCfg := &tls.Config{ServerName: "irc.example.org", Certificates: []tls.Certificate{{}}}
+	pp := newPuppets(&Config{IRCChan: "#slirctest", IRCSSL: true}, tlsCfg, func(name, line string) {})
+
+	puppetCfg := pp.newConn("alice[s]").Config().SSLConfig
+	if puppetCfg == nil || puppetCfg.ServerName != "irc.example.org" {
+		t.Fatalf("expected the bot's TLS settings - got: %v", puppetCfg)
+	}
+	if len(puppetCfg.Certificates) != 0 {
+		t.Error("puppet presents the bot's client certificate")
+	}
+	if len(tlsCfg.Certificates) != 1 {
+		t.Error("the bot's TLS config has been modified")
+	}
+}
+
+func TestPuppetBots(t *testing.T) {
+	pp := newPuppets(&Config{IRCChan: "#slirctest"}, nil, func(name, line string) {})
+	pp.newConn = func(nick string) *ircc.Conn {
+		t.Fatalf("puppet %s started for a bot", nick)
+		return nil
+	}
+
+	for _, payload := range []string{
+		`{"type":"message","subtype":"bot_message","bot_id":"B1","username":"ci","text":"build failed","channel":"C1"}`,
+		`{"type":"message","subtype":"bot_message","bot_id":"B2","username":"pagerduty","text":"incident opened","channel":"C1"}`,
+	} {
+		var e slack.Event
+		if err := json.Unmarshal([]byte(payload), &e); err != nil {
+			t.Fatal(err)
+		}
+		e.Username = e.BotName
+		if pp.send(e.UserID, e.Usernick(), []string{e.Text}) {
+			t.Errorf("message of bot %s relayed by a puppet", e.BotID)
+		}
+	}
+	if len(pp.byUser) != 0 {
+		t.Errorf("expected no puppets for bots - got: %d", len(pp.byUser))
+	}
+}
@@ -0,0 +1,123 @@
+package slirc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// redacted replaces secrets in relayed messages
+const redacted = "[redacted]"
+
+// secretPattern detects one kind of secret. If re has a group named "secret",
+// only that group is redacted, so e.g. "password: [redacted]" keeps its context.
+type secretPattern struct {
+	kind string
+	re   *regexp.Regexp
+}
+
+// builtinSecrets are well-known secret formats that are always redacted
+var builtinSecrets = []secretPattern{
+	{"slack_token", regexp.MustCompile(`xox[abposr]-[0-9A-Za-z-]{10,}`)},
+	{"slack_webhook", regexp.MustCompile(`https://hooks\.slack\.com/services/[0-9A-Za-z/_-]+`)},
+	{"aws_access_key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"aws_secret_key", regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?(?P<secret>[0-9A-Za-z/+=]{40})`)},
+	{"github_token", regexp.MustCompile(`\b(?:gh[pousr]_[0-9A-Za-z]{36,}|github_pat_[0-9A-Za-z_]{60,})\b`)},
+	{"private_key", regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*?(?:-----END [A-Z ]*PRIVATE KEY-----|$)`)},
+	{"password", regexp.MustCompile(`(?i)\b(?:password|passwd|pwd)\s*[:=]\s*(?P<secret>\S+)`)},
+}
+
+// redactor replaces secrets in messages
+type redactor struct {
+	patterns []secretPattern
+}
+
+// newRedactor returns a redactor for the builtin secrets and the additional regexps
+func newRedactor(extra []string) (*redactor, error) {
+	rd := &redactor{patterns: append([]secretPattern(nil), builtinSecrets...)}
+	for _, expr := range extra {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("RedactPatterns: %v", err)
+		}
+		rd.patterns = append(rd.patterns, secretPattern{"custom", re})
+	}
+	return rd, nil
+}
+
+// redact returns text with all secrets replaced, and the kinds of secrets found
+func (rd *redactor) redact(text string) (string, []string) {
+	var kinds []string
+	for _, p := range rd.patterns {
+		group := secretGroup(p.re)
+		var b strings.Builder
+		last, found := 0, false
+		for _, m := range p.re.FindAllStringSubmatchIndex(text, -1) {
+			start, end := m[0], m[1]
+			if group > 0 && m[2*group] >= 0 {
+				start, end = m[2*group], m[2*group+1]
+			}
+			// already redacted by another pattern
+			if text[start:end] == redacted {
+				continue
+			}
+			b.WriteString(text[last:start])
+			b.WriteString(redacted)
+			last, found = end, true
+		}
+		if !found {
+			continue
+		}
+		b.WriteString(text[last:])
+		text = b.String()
+		kinds = append(kinds, p.kind)
+	}
+	return text, kinds
+}
+
+// secretGroup returns the index of the group named "secret" in re, or -1
+func secretGroup(re *regexp.Regexp) int {
+	for i, name := range re.SubexpNames() {
+		if name == "secret" {
+			return i
+		}
+	}
+	return -1
+}
+
+// redactMessage redacts a message relayed in direction ("slack_to_irc" or "irc_to_slack")
+// and counts the redactions
+func (bridge *Bridge) redactMessage(direction, text string) (string, []string) {
+	text, kinds := bridge.redactor.redact(text)
+	for _, kind := range kinds {
+		bridge.metrics.inc("slirc_redactions_total", "Secrets redacted from relayed messages.",
+			fmt.Sprintf(`direction=%q,kind=%q`, direction, kind))
+	}
+	return text, kinds
+}
+
+// redactSlack redacts a slack message before it is relayed to irc and, if
+// configured, warns the author by DM
+func (bridge *Bridge) redactSlack(userID, text string) string {
+	if bridge.redactor == nil {
+		return text
+	}
+	text, kinds := bridge.redactMessage("slack_to_irc", text)
+	if len(kinds) > 0 && bridge.redactWarn && userID != "" {
+		warning := fmt.Sprintf("Your message looked like it contained a secret (%s). It has been replaced by %s on IRC, but it is still visible on Slack. Please consider it compromised and rotate it.",
+			strings.Replace(strings.Join(kinds, ", "), "_", " ", -1), redacted)
+		if err := bridge.slack.SendIM(userID, warning); err != nil {
+			bridge.log.Warn("Failed to warn about a redacted secret", "side", "slack", "user", userID, "err", err)
+		}
+	}
+	return text
+}
+
+// redactIRC redacts an irc message before it is relayed to slack, if configured
+func (bridge *Bridge) redactIRC(text string) string {
+	if bridge.redactor == nil || !bridge.redactIRCToSlack {
+		return text
+	}
+	text, _ = bridge.redactMessage("irc_to_slack", text)
+	return text
+}
@@ -0,0 +1,89 @@
+package slirc
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/emersion/go-sasl"
+	ircc "github.com/fluffle/goirc/client"
+)
+
+// SASL numerics, see https://ircv3.net/specs/extensions/sasl-3.1
+const (
+	rplSASLSuccess = "903"
+	errNickLocked  = "902"
+	errSASLFail    = "904"
+	errSASLTooLong = "905"
+	errSASLAborted = "906"
+)
+
+// errSASLPlaintext refuses SASL PLAIN on connections without TLS
+var errSASLPlaintext = errors.New("IRCSASLUser requires IRCSSL, SASL PLAIN would send the password in clear text")
+
+// values of Bridge.saslState
+const (
+	saslNotFinished = 0
+	saslSucceeded   = 1
+)
+
+// ircSASL returns the SASL mechanism configured for the bridge's irc connection, or nil
+func (c *Config) ircSASL() sasl.Client {
+	switch {
+	case c.IRCSASLExternal:
+		return sasl.NewExternalClient("")
+	case c.IRCSASLUser != "":
+		return sasl.NewPlainClient("", c.IRCSASLUser, c.IRCSASLPassword)
+	}
+	return nil
+}
+
+// checkSASLPlain returns errSASLPlaintext if SASL PLAIN is configured without TLS
+func (c *Config) checkSASLPlain() error {
+	if c.IRCSASLUser != "" && !c.IRCSASLExternal && !c.IRCSSL {
+		return errSASLPlaintext
+	}
+	return nil
+}
+
+// handleSASL makes failed SASL logins visible. Instead of continuing unauthenticated,
+// the connection is closed and the error is reported to the health endpoints.
+func (bridge *Bridge) handleSASL(c *Config) {
+	bridge.requireSASL = true
+
+	bridge.irc.HandleFunc(ircc.REGISTER,
+		func(conn *ircc.Conn, line *ircc.Line) {
+			atomic.StoreInt32(&bridge.saslState, saslNotFinished)
+		})
+
+	bridge.irc.HandleFunc(rplSASLSuccess,
+		func(conn *ircc.Conn, line *ircc.Line) {
+			atomic.StoreInt32(&bridge.saslState, saslSucceeded)
+			bridge.log.Info("SASL authentication successful", "side", "irc", "server", c.IRCServer)
+		})
+
+	fail := func(conn *ircc.Conn, line *ircc.Line) {
+		bridge.saslFailed(conn, fmt.Errorf("SASL authentication failed: %s (%s)", line.Text(), line.Cmd))
+	}
+	for _, numeric := range []string{errNickLocked, errSASLFail, errSASLTooLong, errSASLAborted} {
+		bridge.irc.HandleFunc(numeric, fail)
+	}
+}
+
+// checkSASL is called once registration completed and closes the connection
+// if SASL is required but did not succeed, e.g. because the server does not support it
+func (bridge *Bridge) checkSASL(conn *ircc.Conn) bool {
+	if !bridge.requireSASL || atomic.LoadInt32(&bridge.saslState) == saslSucceeded {
+		return true
+	}
+	bridge.saslFailed(conn, errors.New("SASL authentication failed: registration completed without SASL, does the server support it?"))
+	return false
+}
+
+func (bridge *Bridge) saslFailed(conn *ircc.Conn, err error) {
+	bridge.ircHealth.setError(err)
+	bridge.log.Error("Closing IRC connection", "side", "irc", "err", err)
+	atomic.StoreInt32(&bridge.ircBackoff, 1)
+	conn.Quit(err.Error())
+	conn.Close()
+}
@@ -0,0 +1,29 @@
+package slirc
+
+import (
+	"testing"
+)
+
+func TestIRCSASL(t *testing.T) {
+	if mech := (&Config{}).ircSASL(); mech != nil {
+		t.Errorf("expected no SASL mechanism, got %v", mech)
+	}
+
+	cases := []struct {
+		conf *Config
+		mech string
+		ir   string
+	}{
+		{&Config{IRCSASLUser: "slirc", IRCSASLPassword: "hunter2"}, "PLAIN", "\x00slirc\x00hunter2"},
+		{&Config{IRCSASLExternal: true, IRCSASLUser: "ignored"}, "EXTERNAL", ""},
+	}
+	for _, c := range cases {
+		mech, ir, err := c.conf.ircSASL().Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mech != c.mech || string(ir) != c.ir {
+			t.Errorf("ircSASL - expected: (%v, %q) - got: (%v, %q)", c.mech, c.ir, mech, ir)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors returned by SendContext
+var (
+	ErrChannelNotFound = errors.New("channel not found")
+	ErrRateLimited     = errors.New("rate limited")
+	ErrMsgTooLong      = errors.New("message too long")
+	// ErrNotAcknowledged means the connection was lost after the message had been
+	// written, so it may or may not have been posted
+	ErrNotAcknowledged = errors.New("connection lost before the message was acknowledged")
+)
+
+// RTMError is an error slack returned for a message sent over the websocket
+type RTMError struct {
+	Code int
+	Msg  string
+}
+
+func (e *RTMError) Error() string {
+	return fmt.Sprintf("slack rejected the message: %s (%d)", e.Msg, e.Code)
+}
+
+// ackResult is the outcome of sending a message that waits for its acknowledgement
+type ackResult struct {
+	ts  string
+	err error
+}
+
+// ack is slack's reply to a message sent over the websocket
+// See https://api.slack.com/rtm#sending_messages
+type ack struct {
+	Ok      bool   `json:"ok"`
+	ReplyTo int64  `json:"reply_to"`
+	Ts      string `json:"ts"`
+	Error   *Error `json:"error"`
+}
+
+// ackErrCodes maps the error codes of negative acknowledgements to the errors of SendContext
+var ackErrCodes = map[string]error{
+	"channel_not_found": ErrChannelNotFound,
+	"rate_limited":      ErrRateLimited,
+	"ratelimited":       ErrRateLimited,
+	"msg_too_long":      ErrMsgTooLong,
+}
+
+// ackErrNumbers maps the numeric codes of negative acknowledgements. Slack answers
+// flooding with code -1, "Slow down, too many messages...".
+var ackErrNumbers = map[int]error{
+	-1: ErrRateLimited,
+}
+
+// ackErr maps the error of a negative acknowledgement to the errors of SendContext.
+// Error codes are matched first; the message text is only searched if there is no known code.
+func ackErr(e *Error) error {
+	if e == nil {
+		return &RTMError{Msg: "unknown error"}
+	}
+	msg := strings.ToLower(strings.TrimSpace(e.Msg))
+	if err, ok := ackErrCodes[msg]; ok {
+		return err
+	}
+	if err, ok := ackErrNumbers[e.Code]; ok {
+		return err
+	}
+	switch {
+	case strings.Contains(msg, "channel not found"):
+		return ErrChannelNotFound
+	case strings.Contains(msg, "rate limit"):
+		return ErrRateLimited
+	case strings.Contains(msg, "too long"):
+		return ErrMsgTooLong
+	}
+	return &RTMError{Code: e.Code, Msg: e.Msg}
+}
+
+// expectAck registers the waiter of the message with id; it is called before the message is written
+func (sc *Client) expectAck(id int64, acked chan<- ackResult) {
+	sc.ackmu.Lock()
+	defer sc.ackmu.Unlock()
+	if sc.acks == nil {
+		sc.acks = make(map[int64]chan<- ackResult)
+	}
+	sc.acks[id] = acked
+}
+
+// resolveAck hands the result for the message with id to its waiter, if any
+func (sc *Client) resolveAck(id int64, res ackResult) {
+	sc.ackmu.Lock()
+	acked, ok := sc.acks[id]
+	delete(sc.acks, id)
+	sc.ackmu.Unlock()
+	if ok {
+		acked <- res
+	}
+}
+
+// forgetAck removes the waiter of a message that has not been written
+func (sc *Client) forgetAck(id int64) {
+	sc.ackmu.Lock()
+	delete(sc.acks, id)
+	sc.ackmu.Unlock()
+}
+
+// handleAck processes a reply_to message
+func (sc *Client) handleAck(msg []byte) error {
+	var a ack
+	if err := json.Unmarshal(msg, &a); err != nil {
+		return err
+	}
+	if a.Ok {
+		sc.resolveAck(a.ReplyTo, ackResult{ts: a.Ts})
+	} else {
+		sc.resolveAck(a.ReplyTo, ackResult{err: ackErr(a.Error)})
+	}
+	return nil
+}
+
+// failAcks fails all messages waiting for an acknowledgement, after the connection has been lost
+func (sc *Client) failAcks() {
+	sc.ackmu.Lock()
+	acks := sc.acks
+	sc.acks = nil
+	sc.ackmu.Unlock()
+	for _, acked := range acks {
+		acked <- ackResult{err: ErrNotAcknowledged}
+	}
+}
+
+// isChannelID reports whether s is a channel, private channel or IM ID like "C024BE91L".
+// Channel names cannot contain upper case letters.
+func isChannelID(s string) bool {
+	if s == "" || !strings.ContainsRune("CGD", rune(s[0])) {
+		return false
+	}
+	for _, r := range s {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// SendContext sends text to channel, a channel name or ID, and waits until slack
+// acknowledges it. It returns the ts of the posted message, or ErrChannelNotFound,
+// ErrRateLimited, ErrMsgTooLong, an *RTMError, ErrNotAcknowledged or ErrQueueFull.
+// Messages that could not be written are sent again after a reconnect. With the QueueBlock
+// overflow policy, it waits for room in the outbound queue. With ordered dispatch, it
+// must not be called from the handler of a channel event.
+func (sc *Client) SendContext(ctx context.Context, channel, text string) (ts string, err error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	acked := make(chan ackResult, 1)
+	event := &Event{Type: "message", Channelname: channel, Text: text, acked: acked}
+	if isChannelID(channel) {
+		event.ChannelID, event.Channelname = channel, ""
+	}
+	if err := sc.enqueue(ctx, event, true); err != nil {
+		return "", err
+	}
+	select {
+	case res := <-acked:
+		return res.ts, res.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
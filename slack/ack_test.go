@@ -0,0 +1,128 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newRTMStandIn serves rtm.start and a websocket that acknowledges messages
+// depending on their text: "too long" is rejected and "drop" closes the connection.
+// Accepted messages are echoed back as message events, as slack does.
+func newRTMStandIn(t *testing.T) *httptest.Server {
+	var srv *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rtm.start", func(w http.ResponseWriter, r *http.Request) {
+		wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+		fmt.Fprintf(w, `{"ok":true,"self":{"id":"U0","name":"slirc"},"channels":[{"id":"C1","name":"general"}],"url":%q}`, wsURL)
+	})
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		conn.WriteJSON(map[string]string{"type": "hello"})
+		for {
+			var msg struct {
+				ID      int64  `json:"id"`
+				Channel string `json:"channel"`
+				Text    string `json:"text"`
+			}
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			switch strings.TrimSuffix(msg.Text, bridgeMarker) {
+			case "drop":
+				return
+			case "too long":
+				conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(`{"ok":false,"reply_to":%d,"error":{"code":11,"msg":"msg_too_long"}}`, msg.ID)))
+			default:
+				conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(`{"ok":true,"reply_to":%d,"ts":"1355517523.%06d","text":%q}`, msg.ID, msg.ID, msg.Text)))
+				conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(`{"type":"message","channel":%q,"user":"U0","text":%q,"ts":"1355517523.%06d"}`, msg.Channel, msg.Text, msg.ID)))
+			}
+		}
+	})
+	srv = httptest.NewServer(mux)
+	return srv
+}
+
+func TestSendContext(t *testing.T) {
+	srv := newRTMStandIn(t)
+	defer srv.Close()
+
+	sc := NewClient("foobar")
+	sc.APIURL = srv.URL + "/"
+	disconnected := make(chan struct{}, 1)
+	sc.HandleFunc("disconnected", func(sc *Client, e *Event) { disconnected <- struct{}{} })
+	if err := sc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer sc.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ts, err := sc.SendContext(ctx, "general", "hello")
+	if err != nil || ts != "1355517523.000001" {
+		t.Errorf("SendContext - got: (%v, %v)", ts, err)
+	}
+	ts, err = sc.SendContext(ctx, "C1", "hello again")
+	if err != nil || ts != "1355517523.000002" {
+		t.Errorf("SendContext by channel ID - got: (%v, %v)", ts, err)
+	}
+	if _, err := sc.SendContext(ctx, "general", "too long"); err != ErrMsgTooLong {
+		t.Errorf("expected ErrMsgTooLong - got: %v", err)
+	}
+	if _, err := sc.SendContext(ctx, "nosuchchannel", "hello"); err != ErrChannelNotFound {
+		t.Errorf("expected ErrChannelNotFound - got: %v", err)
+	}
+	if _, err := sc.SendContext(ctx, "general", "drop"); err != ErrNotAcknowledged {
+		t.Errorf("expected ErrNotAcknowledged - got: %v", err)
+	}
+
+	// message IDs are not reused after reconnecting, late acknowledgements cannot resolve new messages
+	<-disconnected
+	if err := sc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if ts, err := sc.SendContext(ctx, "general", "hello"); err != nil || ts != "1355517523.000005" {
+		t.Errorf("SendContext after reconnecting - got: (%v, %v)", ts, err)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := sc.SendContext(canceled, "general", "hello"); err != context.Canceled {
+		t.Errorf("expected context.Canceled - got: %v", err)
+	}
+}
+
+func TestAckErr(t *testing.T) {
+	tests := []struct {
+		in   *Error
+		want error
+	}{
+		{&Error{Code: 1, Msg: "channel_not_found"}, ErrChannelNotFound},
+		{&Error{Code: -1, Msg: "Slow down, too many messages..."}, ErrRateLimited},
+		{&Error{Code: 11, Msg: "msg_too_long"}, ErrMsgTooLong},
+		{&Error{Code: 3, Msg: "Channel not found"}, ErrChannelNotFound},
+	}
+	for _, tt := range tests {
+		if got := ackErr(tt.in); got != tt.want {
+			t.Errorf("ackErr(%v) - expected: %v - got: %v", tt.in.Msg, tt.want, got)
+		}
+	}
+	for _, e := range []*Error{{Code: 2, Msg: "message text is missing"}, {Code: 4, Msg: "too many attachments"}} {
+		if err, ok := ackErr(e).(*RTMError); !ok || err.Code != e.Code {
+			t.Errorf("ackErr(%v) - expected an *RTMError - got: %v", e.Msg, err)
+		}
+	}
+}
@@ -1,7 +1,12 @@
 package slack
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -9,30 +14,58 @@ import (
 type Client struct {
 	BotToken  string
 	UserToken string
-	nextID    int64
+	// APIURL is the base URL of the Web API, DefaultAPIURL unless changed for testing
+	APIURL string
+	nextID int64
 
-	handlers map[string][]HandlerFunc
+	// UserMention, if set, renders highlights of slack users in incoming messages.
+	// If it returns "", the user's name is used.
+	UserMention func(userID string) string
 
-	self  Self
-	users []User
+	// FilePublished, if set, is called for every file the client made public using UserToken
+	FilePublished func(f *File)
+	// EventDropped, if set, is called for every event dropped because the handlers
+	// fell behind, see EnableOrderedDispatch
+	EventDropped func(e *Event)
 
-	userIDMap map[string]*User
-	channels  []Channel
-	chanIDMap map[string]*Channel
-	chanMap   map[string]*Channel // lookup by channame
+	// NoPublicLinks keeps the client from making files public, UserToken is then
+	// only used to revoke links created earlier
+	NoPublicLinks bool
 
-	quit chan struct{}
-	in   chan *Event
-	out  chan *Event
+	hmu        sync.RWMutex
+	handlers   map[string][]*Registration
+	middleware []Middleware
+	dispatcher *dispatcher // nil: every handler runs in its own goroutine
 
-	sharemu sync.Mutex
-	shared  map[string]bool
+	// dir holds self, users and channels
+	dir directory
+
+	immu    sync.Mutex
+	imIDMap map[string]string // IM channel ID by user ID
+
+	quit  chan struct{}
+	queue *outQueue // events waiting to be written
+
+	shares *sharePipeline
+
+	limitmu  sync.Mutex
+	limiters map[string]*rateLimiter // by Web API method
+	rtmLimit *rateLimiter            // paces messages written to the websocket
+
+	ackmu sync.Mutex
+	acks  map[int64]chan<- ackResult // messages waiting for their reply_to, by ID
 
 	mu        sync.RWMutex
 	connected bool
+	lastPong  time.Time
 
 	wg sync.WaitGroup
 	ws *websocket.Conn
+
+	logger Logger
+
+	httpClient *http.Client
+	wsDialer   *websocket.Dialer
 }
 
 type Self struct {
@@ -40,66 +73,79 @@ type Self struct {
 	Name string
 }
 
-type HandlerFunc func(*Client, *Event)
-
-func (sc *Client) HandleFunc(msgType string, hf HandlerFunc) {
-	sc.handlers[msgType] = append(sc.handlers[msgType], hf)
+func NewClient(botToken string) (sc *Client) {
+	sc = &Client{BotToken: botToken, APIURL: DefaultAPIURL, nextID: 1}
+	sc.httpClient = &http.Client{Timeout: httpTimeout}
+	dialer := *websocket.DefaultDialer
+	sc.wsDialer = &dialer
+	sc.queue = newOutQueue(DefaultQueueSize, QueueDropOldest)
+	sc.rtmLimit = newRateLimiter(rtmMessageLimit)
+	sc.handlers = make(map[string][]*Registration)
+	sc.shares = newSharePipeline()
+	sc.logger = RedactLogger(NewStdLogger(nil, false))
+	return sc
 }
 
-func (sc *Client) disPatchHandlers(event *Event) {
-	if handlers, ok := sc.handlers[event.Type]; ok {
-		for _, handler := range handlers {
-			go handler(sc, event)
-		}
+// SetProxy routes all HTTP and websocket connections to Slack through the proxy at proxyURL,
+// e.g. "socks5://127.0.0.1:1080" or "http://proxy.example.org:3128" (HTTP CONNECT).
+func (sc *Client) SetProxy(proxyURL string) error {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return fmt.Errorf("invalid proxy URL: %v", err)
+	}
+	switch u.Scheme {
+	case "socks5", "socks5h", "http":
+	default:
+		return fmt.Errorf("unsupported proxy scheme %q, use socks5 or http", u.Scheme)
 	}
+	if u.Host == "" {
+		return fmt.Errorf("missing host in proxy URL %q", proxyURL)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(u)
+	sc.httpClient = &http.Client{Transport: transport, Timeout: httpTimeout}
+
+	dialer := *websocket.DefaultDialer
+	dialer.Proxy = http.ProxyURL(u)
+	sc.wsDialer = &dialer
+	return nil
 }
 
-func NewClient(botToken string) (sc *Client) {
-	sc = &Client{BotToken: botToken}
-	sc.in = make(chan *Event, 3)
-	sc.handlers = make(map[string][]HandlerFunc)
-	sc.shared = make(map[string]bool)
-	return sc
+// SetLogger replaces the Client's Logger. Slack tokens are always redacted.
+func (sc *Client) SetLogger(l Logger) {
+	sc.logger = RedactLogger(l, sc.BotToken, sc.UserToken)
 }
 
 func (sc *Client) Send(target, msg string) {
 	sc.send(&Event{Type: "message", Channelname: target, Text: msg})
 }
 
+// Relay sends msg, relayed from another network, to the channel target. It carries
+// the bridge marker, so other bridges in the channel do not relay it once more.
+func (sc *Client) Relay(target, msg string) {
+	sc.send(&Event{Type: "message", Channelname: target, Text: markBridged(msg)})
+}
+
+// send queues event without blocking, see SetOutboundQueue
 func (sc *Client) send(event *Event) {
-	sc.in <- event
+	sc.enqueue(context.Background(), event, false)
 }
 
 func (sc *Client) updateUser(user *User) {
-	sc.userIDMap[user.ID] = user
+	sc.dir.setUser(*user)
 }
 
 func (sc *Client) bookKeeping(apiResp *APIResp) {
-	// store self infos
-	sc.self = apiResp.Self
-
-	// store userInfo
-	sc.users = apiResp.Users
-
-	//store chanInfo
-	sc.channels = apiResp.Channels
-
-	// create map for User lookups by ID
-	sc.userIDMap = make(map[string]*User)
-	// populate map
-	for i, user := range sc.users {
-		sc.userIDMap[user.ID] = &sc.users[i]
-	}
-
-	//create map for Chan lookups by ID
-	sc.chanIDMap = make(map[string]*Channel)
-	//create map for Chan lookups by Name
-	sc.chanMap = make(map[string]*Channel)
-	// populate maps
-	for i := range sc.channels {
-		channel := &sc.channels[i]
-		sc.chanIDMap[channel.ID] = channel
-		sc.chanMap[channel.Name] = channel
+	// store self, user and channel infos
+	channels := append(append([]Channel(nil), apiResp.Channels...), apiResp.Groups...)
+	sc.dir.reset(apiResp.Self, apiResp.Users, channels)
+
+	// create map for IM lookups by user ID
+	sc.immu.Lock()
+	sc.imIDMap = make(map[string]string)
+	for _, im := range apiResp.IMs {
+		sc.imIDMap[im.User] = im.ID
 	}
+	sc.immu.Unlock()
 
 }
@@ -43,8 +43,8 @@ func TestBookKeeping(t *testing.T) {
 	sc := setup(t)
 
 	for k, v := range wantChan {
-		chan1, ok1 := sc.chanIDMap[k]
-		chan2, ok2 := sc.chanMap[v]
+		chan1, ok1 := sc.LookupChannel(k)
+		chan2, ok2 := sc.LookupChannelByName(v)
 		if !ok1 || !ok2 || chan1 != chan2 {
 			t.FailNow()
 		}
@@ -54,7 +54,7 @@ func TestBookKeeping(t *testing.T) {
 	}
 
 	for k, v := range wantUser {
-		user, ok := sc.userIDMap[k]
+		user, ok := sc.LookupUser(k)
 		if !ok {
 			t.FailNow()
 		}
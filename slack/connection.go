@@ -5,8 +5,6 @@ import (
 	"fmt"
 	"html"
 	"io/ioutil"
-	"log"
-	"net/http"
 	"net/url"
 	"strings"
 	"time"
@@ -20,6 +18,12 @@ const (
 
 	// Send pings to peer with this period. Must be less than pongWait.
 	pingPeriod = (pongWait * 9) / 10
+
+	// Time allowed for a Web API request.
+	httpTimeout = 30 * time.Second
+
+	// Messages per minute written to the websocket, slack allows about one per second.
+	rtmMessageLimit = 60
 )
 
 // APIResp represents the API response of rtm.start
@@ -30,14 +34,18 @@ type APIResp struct {
 	Error    string    `json:"error"`
 	Users    []User    `json:"users"`
 	Channels []Channel `json:"channels"`
+	Groups   []Channel `json:"groups"` // private channels the bot is a member of
+	IMs      []IM      `json:"ims"`
 	URL      string    `json:"url"`
 }
 
 type EventType struct {
-	Type string `json:"type"`
+	Type    string `json:"type"`
+	ReplyTo int64  `json:"reply_to,omitempty"` // set on acknowledgements of sent messages
 }
 
-const slackAPIEndpoint = "https://slack.com/api/rtm.start"
+// DefaultAPIURL is the base URL of the Slack Web API
+const DefaultAPIURL = "https://slack.com/api/"
 
 func (sc *Client) Connected() bool {
 	sc.mu.RLock()
@@ -45,6 +53,14 @@ func (sc *Client) Connected() bool {
 	return sc.connected
 }
 
+// LastPong returns the time at which the last pong (or, right after
+// connecting, the hello) was received on the websocket.
+func (sc *Client) LastPong() time.Time {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	return sc.lastPong
+}
+
 func (sc *Client) Connect() (err error) {
 	err = sc.connect()
 	return err
@@ -56,18 +72,19 @@ func (sc *Client) connect() (err error) {
 
 	wsAddr, err := sc.startRTM()
 	if err != nil {
-		log.Println("SlackRTMStart failed: ", err)
+		sc.logger.Error("SlackRTMStart failed", "side", "slack", "err", err)
 		return err
 	}
 	err = sc.connectWS(wsAddr)
 	if err != nil {
-		log.Println("SlackWS reconnect failed: ", err)
+		sc.logger.Error("SlackWS connect failed", "side", "slack", "err", err)
 		return err
 	}
 
 	// success
 	sc.mu.Lock()
 	sc.connected = true
+	sc.lastPong = time.Now()
 	sc.mu.Unlock()
 
 	sc.wg.Add(2)
@@ -84,14 +101,18 @@ func (sc *Client) readLoop() {
 	defer sc.wg.Done()
 	sc.ws.SetReadDeadline(time.Now().Add(pongWait))
 	sc.ws.SetPongHandler(func(string) error {
-		sc.ws.SetReadDeadline(time.Now().Add(pongWait))
+		now := time.Now()
+		sc.mu.Lock()
+		sc.lastPong = now
+		sc.mu.Unlock()
+		sc.ws.SetReadDeadline(now.Add(pongWait))
 		return nil
 	})
 
 	for {
 		messageType, r, err := sc.ws.NextReader()
 		if err != nil {
-			log.Println(err)
+			sc.logger.Warn("Websocket read failed", "side", "slack", "err", err)
 			// If we do not start a seperate Goroutine and return,
 			// we will never decrease our wg counter
 			go sc.handleDisconnect()
@@ -100,7 +121,7 @@ func (sc *Client) readLoop() {
 
 		msg, err := ioutil.ReadAll(r)
 		if err != nil {
-			log.Println(err)
+			sc.logger.Warn("Websocket read failed", "side", "slack", "err", err)
 			go sc.handleDisconnect()
 			return
 		}
@@ -108,30 +129,53 @@ func (sc *Client) readLoop() {
 		// unmarshal to temp struct and check whether it is a bookkeeping event, or a regular event
 		var et EventType
 		if err := json.Unmarshal(msg, &et); err != nil {
-			log.Printf("Failed to unmarshal the following rawEvent with messageType: %v \n", messageType)
-			log.Println(string(msg))
+			sc.logUnmarshalError(messageType, "", msg, err)
+			continue
+		}
+
+		if et.ReplyTo != 0 {
+			if err := sc.handleAck(msg); err != nil {
+				sc.logUnmarshalError(messageType, "reply_to", msg, err)
+			}
 			continue
 		}
 
 		if et.Type == "file_public" {
-			if sc.UserToken != "" {
+			if sc.UserToken != "" && !sc.NoPublicLinks {
 				var fe FileEvent
 				if err := json.Unmarshal(msg, &fe); err != nil {
-					log.Printf("Failed to unmarshal the following rawEvent with messageType: %v \n", messageType)
-					log.Println(string(msg))
+					sc.logUnmarshalError(messageType, et.Type, msg, err)
 					continue
 				}
-				go sc.shareFile(fe.FileID)
+				sc.queueShare(fe.FileID)
 			}
 
 		}
 
+		// channel directory events
+		if channelEvents[et.Type] {
+			var ce ChannelEvent
+			if err := json.Unmarshal(msg, &ce); err != nil {
+				sc.logUnmarshalError(messageType, et.Type, msg, err)
+				continue
+			}
+			ce.Raw = msg
+			event, err := sc.applyChannelEvent(&ce)
+			if err != nil {
+				sc.logger.Warn("Failed to update channel directory", "side", "slack", "type", et.Type, "err", err)
+				continue
+			}
+			if event != nil {
+				sc.disPatchHandlers(event)
+			}
+			continue
+		}
+
 		// bookkeeping event
 		if et.Type == "user_change" || et.Type == "team_join" {
 			var ue UserEvent
 			if err := json.Unmarshal(msg, &ue); err != nil {
-				log.Printf("Failed to unmarshal the following rawEvent with messageType: %v \n", messageType)
-				log.Println(string(msg))
+				sc.logUnmarshalError(messageType, et.Type, msg, err)
 				continue
 			}
 			sc.updateUser(ue.User)
@@ -141,62 +185,85 @@ func (sc *Client) readLoop() {
 		// normal event
 		var event Event
 		if err := json.Unmarshal(msg, &event); err != nil {
-			log.Printf("Failed to unmarshal the following rawEvent with messageType: %v \n", messageType)
-			log.Println(string(msg))
+			sc.logUnmarshalError(messageType, et.Type, msg, err)
 			continue
 		}
+		event.Raw = msg
 		event.Text = html.UnescapeString(bracketRe.ReplaceAllStringFunc(event.Text, sc.unSlackify))
 		sc.idToName(&event)
 
 		// is this a command?
-		if strings.HasPrefix(event.Text, fmt.Sprint("@", sc.self.Name)) {
+		self := sc.dir.getSelf()
+		if strings.HasPrefix(event.Text, fmt.Sprint("@", self.Name)) {
 			event.Type = "command"
-			if len(event.Text) > len(sc.self.Name)+1 {
-				event.Text = strings.TrimSpace(event.Text[len(sc.self.Name)+1:])
+			if len(event.Text) > len(self.Name)+1 {
+				event.Text = strings.TrimSpace(event.Text[len(self.Name)+1:])
 			}
-			user, ok := sc.userIDMap[event.UserID]
+			user, ok := sc.dir.user(event.UserID)
 			if ok && user.IsAdmin {
-				log.Println("admin-command found: ", event.Text)
+				sc.logger.Info("admin-command found", "side", "slack", "channel", event.Chan(), "user", event.Usernick(), "command", event.Text)
 				event.Type = "admincommand"
 			}
 		}
-		go sc.disPatchHandlers(&event)
+		sc.disPatchHandlers(&event)
 	}
 }
 
+// logUnmarshalError logs a failed decode without dumping the payload, which may carry tokens
+func (sc *Client) logUnmarshalError(messageType int, eventType string, msg []byte, err error) {
+	sc.logger.Warn("Failed to unmarshal event", "side", "slack", "type", eventType, "messageType", messageType, "size", len(msg), "err", err)
+}
+
 func (sc *Client) writeLoop() {
 	ticker := time.NewTicker(pingPeriod)
-
+	// messages are paced, so a queue filled while slack was unreachable
+	// is not sent in a burst after reconnecting
+	rl := sc.rtmLimit
+	ready := sc.queue.ready
+	var pending *Event // waits for paced
+	paced := time.NewTimer(0)
+	<-paced.C
+
+	defer paced.Stop()
 	defer ticker.Stop()
 	defer sc.wg.Done()
 
 	for {
 		select {
 		case <-sc.quit:
+			if pending != nil {
+				rl.unreserve()
+				sc.requeue(pending)
+			}
 			return
 
-		case event := <-sc.in:
-			// replace Channel Name with ID
-			channel, ok := sc.chanMap[event.Chan()]
-			if !ok {
-				log.Printf("Unknown Channel %s \n", event.Chan())
+		case <-ready:
+			event := sc.queue.pop()
+			if event == nil || !sc.resolveChannel(event) {
 				continue
 			}
-			event.ChannelID = channel.ID
-			// set event's ID
-			event.ID = sc.nextID
+			if delay := rl.reserve(); delay > 0 {
+				pending, ready = event, nil
+				paced.Reset(delay)
+				continue
+			}
+			if !sc.write(event) {
+				return
+			}
 
-			err := sc.ws.WriteJSON(&event)
-			if err != nil {
-				log.Println(err)
-				// If we do not start a seperate Goroutine and return,
-				// we will never decrease our wg counter
-				go sc.handleDisconnect()
+		case <-paced.C:
+			event := pending
+			pending, ready = nil, sc.queue.ready
+			if !sc.write(event) {
 				return
 			}
-			sc.nextID++
+
 		case <-ticker.C:
 			if err := sc.ws.WriteMessage(websocket.PingMessage, []byte{}); err != nil {
+				if pending != nil {
+					rl.unreserve()
+					sc.requeue(pending)
+				}
 				go sc.handleDisconnect()
 				return
 			}
@@ -204,6 +271,45 @@ func (sc *Client) writeLoop() {
 	}
 }
 
+// resolveChannel replaces the channel name of event with its ID, unless the ID is known
+// already (e.g. for IMs). It fails the event and returns false if the channel is unknown.
+func (sc *Client) resolveChannel(event *Event) bool {
+	if event.ChannelID != "" {
+		return true
+	}
+	channel, ok := sc.dir.channelByName(event.Chan())
+	if !ok {
+		sc.logger.Warn("Unknown Channel", "side", "slack", "channel", event.Chan(), "type", event.Type)
+		if event.acked != nil {
+			event.acked <- ackResult{err: ErrChannelNotFound}
+		}
+		return false
+	}
+	event.ChannelID = channel.ID
+	return true
+}
+
+// write sends event over the websocket. If that fails, the event is queued again
+// to be sent after reconnecting, and write returns false.
+func (sc *Client) write(event *Event) bool {
+	event.ID = sc.nextID
+	if event.acked != nil {
+		sc.expectAck(event.ID, event.acked)
+	}
+	if err := sc.ws.WriteJSON(&event); err != nil {
+		sc.logger.Warn("Websocket write failed", "side", "slack", "err", err)
+		// it has not been sent, so send it after reconnecting
+		sc.forgetAck(event.ID)
+		sc.requeue(event)
+		// If we do not start a seperate Goroutine and return,
+		// we will never decrease our wg counter
+		go sc.handleDisconnect()
+		return false
+	}
+	sc.nextID++
+	return true
+}
+
 func (sc *Client) handleDisconnect() {
 	sc.mu.Lock()
 
@@ -218,14 +324,15 @@ func (sc *Client) handleDisconnect() {
 
 	// Send disconnected event after all goroutines have been stopped.
 	sc.wg.Wait()
-	log.Println("Slack: stopped all Goroutines.")
+	sc.logger.Debug("stopped all Goroutines", "side", "slack")
+	sc.failAcks()
 	dcEvent := &Event{Type: "disconnected"}
 	sc.disPatchHandlers(dcEvent)
 }
 
 // startRTM() calls Slack
 func (sc *Client) startRTM() (wsAddr string, err error) {
-	resp, err := http.PostForm(slackAPIEndpoint, url.Values{"token": {sc.BotToken}})
+	resp, err := sc.httpClient.PostForm(sc.APIURL+"rtm.start", url.Values{"token": {sc.BotToken}})
 	if err != nil {
 		return "", fmt.Errorf("Failed to obtain websocket address: %v", err)
 	}
@@ -249,15 +356,14 @@ func (sc *Client) startRTM() (wsAddr string, err error) {
 }
 
 func (sc *Client) connectWS(wsAddr string) (err error) {
-	log.Println("Connecting to Websocket at address:")
-	log.Println(wsAddr)
-	ws, _, err := websocket.DefaultDialer.Dial(wsAddr, nil)
+	sc.logger.Debug("Connecting to Websocket", "side", "slack", "addr", wsAddr)
+	ws, _, err := sc.wsDialer.Dial(wsAddr, nil)
 	if err != nil {
 		return err
 	}
 	sc.ws = ws
-	// set ID for next send on this connection to 1
-	sc.nextID = 1
+	// nextID keeps increasing across connections, so late acknowledgements
+	// of an old connection cannot resolve messages sent on this one
 
 	var event Event
 	err = sc.ws.ReadJSON(&event)
@@ -275,7 +381,17 @@ func (sc *Client) Close() {
 	// Announce shutdown in progress
 	shutdownEvent := &Event{Type: "shutdown"}
 	sc.disPatchHandlers(shutdownEvent)
-	sc.close()
+
+	// the failing readLoop must neither close again nor announce a disconnect
+	sc.mu.Lock()
+	connected := sc.connected
+	sc.connected = false
+	sc.mu.Unlock()
+	if connected {
+		sc.close()
+		sc.wg.Wait()
+	}
+	sc.failAcks()
 }
 
 func (sc *Client) close() {
@@ -0,0 +1,266 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// channelLookupTimeout bounds looking up a channel the bot has been added to
+const channelLookupTimeout = 5 * time.Second
+
+// directory caches the users and channels of the team. It is safe for concurrent use:
+// entries are never modified in place, updates replace them under the lock.
+type directory struct {
+	mu       sync.RWMutex
+	self     Self
+	users    map[string]*User
+	channels map[string]*Channel // by ID
+	byName   map[string]*Channel
+}
+
+// reset replaces the whole directory, e.g. after rtm.start
+func (d *directory) reset(self Self, users []User, channels []Channel) {
+	userMap := make(map[string]*User, len(users))
+	for i := range users {
+		user := users[i]
+		userMap[user.ID] = &user
+	}
+	chanMap := make(map[string]*Channel, len(channels))
+	byName := make(map[string]*Channel, len(channels))
+	for i := range channels {
+		channel := channels[i]
+		chanMap[channel.ID] = &channel
+		byName[channel.Name] = &channel
+	}
+	d.mu.Lock()
+	d.self, d.users, d.channels, d.byName = self, userMap, chanMap, byName
+	d.mu.Unlock()
+}
+
+func (d *directory) getSelf() Self {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.self
+}
+
+func (d *directory) user(userID string) (User, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if user, ok := d.users[userID]; ok {
+		return *user, true
+	}
+	return User{}, false
+}
+
+func (d *directory) setUser(user User) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.users == nil {
+		d.users = make(map[string]*User)
+	}
+	d.users[user.ID] = &user
+}
+
+func (d *directory) channel(channelID string) (Channel, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if channel, ok := d.channels[channelID]; ok {
+		return *channel, true
+	}
+	return Channel{}, false
+}
+
+func (d *directory) channelByName(name string) (Channel, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if channel, ok := d.byName[name]; ok {
+		return *channel, true
+	}
+	return Channel{}, false
+}
+
+// setChannel adds or replaces ch in the directory
+func (d *directory) setChannel(ch Channel) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.channels == nil {
+		d.channels = make(map[string]*Channel)
+		d.byName = make(map[string]*Channel)
+	}
+	if old, ok := d.channels[ch.ID]; ok && d.byName[old.Name] == old {
+		delete(d.byName, old.Name)
+	}
+	d.channels[ch.ID] = &ch
+	d.byName[ch.Name] = &ch
+}
+
+func (d *directory) removeChannel(channelID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if old, ok := d.channels[channelID]; ok {
+		if d.byName[old.Name] == old {
+			delete(d.byName, old.Name)
+		}
+		delete(d.channels, channelID)
+	}
+}
+
+func (d *directory) allUsers() []User {
+	d.mu.RLock()
+	users := make([]User, 0, len(d.users))
+	for _, user := range d.users {
+		users = append(users, *user)
+	}
+	d.mu.RUnlock()
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users
+}
+
+func (d *directory) allChannels() []Channel {
+	d.mu.RLock()
+	channels := make([]Channel, 0, len(d.channels))
+	for _, channel := range d.channels {
+		channels = append(channels, *channel)
+	}
+	d.mu.RUnlock()
+	sort.Slice(channels, func(i, j int) bool { return channels[i].Name < channels[j].Name })
+	return channels
+}
+
+// LookupUser returns the user with userID
+func (sc *Client) LookupUser(userID string) (User, bool) {
+	return sc.dir.user(userID)
+}
+
+// LookupChannel returns the channel with channelID
+func (sc *Client) LookupChannel(channelID string) (Channel, bool) {
+	return sc.dir.channel(channelID)
+}
+
+// LookupChannelByName returns the channel called name (without "#")
+func (sc *Client) LookupChannelByName(name string) (Channel, bool) {
+	return sc.dir.channelByName(name)
+}
+
+// Users returns all known users, sorted by ID
+func (sc *Client) Users() []User {
+	return sc.dir.allUsers()
+}
+
+// Channels returns all known channels, sorted by name
+func (sc *Client) Channels() []Channel {
+	return sc.dir.allChannels()
+}
+
+// ChannelEvent is a channel_* or group_* event, or member_joined_channel.
+// Depending on the event type, `channel` is either a channel object or an ID.
+type ChannelEvent struct {
+	EventMeta
+	Channel json.RawMessage `json:"channel"`
+	User    string          `json:"user,omitempty"`
+}
+
+// channelEvents are the events that change the channel directory
+var channelEvents = map[string]bool{
+	"channel_created":       true,
+	"channel_rename":        true,
+	"channel_archive":       true,
+	"channel_unarchive":     true,
+	"channel_deleted":       true,
+	"channel_joined":        true,
+	"channel_left":          true,
+	"group_joined":          true,
+	"group_rename":          true,
+	"group_archive":         true,
+	"group_unarchive":       true,
+	"group_deleted":         true,
+	"group_left":            true,
+	"member_joined_channel": true,
+}
+
+// channel returns the channel of ce; only the ID is set if the event carries just the ID
+func (ce *ChannelEvent) channel() (ch Channel, err error) {
+	if len(ce.Channel) > 0 && ce.Channel[0] == '{' {
+		err = json.Unmarshal(ce.Channel, &ch)
+	} else {
+		err = json.Unmarshal(ce.Channel, &ch.ID)
+	}
+	return ch, err
+}
+
+// ChannelID returns the ID of the channel the event is about
+func (ce *ChannelEvent) ChannelID() string {
+	ch, _ := ce.channel()
+	return ch.ID
+}
+
+// applyChannelEvent updates the channel directory and returns the event dispatched to the handlers.
+// Channel renames carry the previous name in PrevChannelname. The event is nil if it is dispatched
+// later, once the channel has been looked up, see lookupJoinedChannel.
+func (sc *Client) applyChannelEvent(ce *ChannelEvent) (*Event, error) {
+	ch, err := ce.channel()
+	if err != nil {
+		return nil, err
+	}
+	if ch.ID == "" {
+		return nil, fmt.Errorf("%s without channel", ce.Type)
+	}
+	event := &Event{Type: ce.Type, ChannelID: ch.ID, UserID: ce.User, Raw: ce.Raw}
+	known, isKnown := sc.dir.channel(ch.ID)
+	if isKnown {
+		event.Channelname = known.Name
+	}
+	prevName := event.Channelname
+
+	switch ce.Type {
+	case "channel_created", "channel_joined", "group_joined", "channel_rename", "group_rename":
+		if isKnown {
+			known.Name = ch.Name
+			ch = known
+		}
+		sc.dir.setChannel(ch)
+	case "channel_archive", "group_archive", "channel_unarchive", "group_unarchive":
+		if isKnown {
+			known.IsArchived = ce.Type == "channel_archive" || ce.Type == "group_archive"
+			sc.dir.setChannel(known)
+		}
+	case "channel_deleted", "group_deleted":
+		sc.dir.removeChannel(ch.ID)
+	case "group_left":
+		// private channels cannot be resolved after the bot left them
+		sc.dir.removeChannel(ch.ID)
+	case "member_joined_channel":
+		// the bot has been added to a channel it did not know, e.g. a private one
+		if !isKnown && ce.User == sc.dir.getSelf().ID {
+			// do not hold up the read loop while slack answers
+			go sc.lookupJoinedChannel(event)
+			return nil, nil
+		}
+	}
+	if channel, ok := sc.dir.channel(ch.ID); ok {
+		event.Channelname = channel.Name
+	}
+	if (ce.Type == "channel_rename" || ce.Type == "group_rename") && prevName != event.Channelname {
+		event.PrevChannelname = prevName
+	}
+	return event, nil
+}
+
+// lookupJoinedChannel adds the channel the bot has been added to to the directory,
+// then dispatches the member_joined_channel event
+func (sc *Client) lookupJoinedChannel(event *Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), channelLookupTimeout)
+	info, err := sc.ConversationInfo(ctx, event.ChannelID)
+	cancel()
+	if err != nil {
+		sc.logger.Warn("Failed to update channel directory", "side", "slack", "type", event.Type, "err", err)
+		return
+	}
+	sc.dir.setChannel(*info)
+	event.Channelname = info.Name
+	sc.disPatchHandlers(event)
+}
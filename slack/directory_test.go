@@ -0,0 +1,149 @@
+package slack
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChannelEvents(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/conversations.info" || r.FormValue("channel") != "G1" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"ok":true,"channel":{"id":"G1","name":"secret-ops","is_group":true}}`)
+	}))
+	defer api.Close()
+
+	sc := setup(t)
+	sc.APIURL = api.URL + "/"
+
+	apply := func(raw string) *Event {
+		t.Helper()
+		var ce ChannelEvent
+		if err := json.Unmarshal([]byte(raw), &ce); err != nil {
+			t.Fatal(err)
+		}
+		ce.Raw = []byte(raw)
+		event, err := sc.applyChannelEvent(&ce)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return event
+	}
+	resolve := func(name string) string {
+		e := &Event{Channelname: name}
+		sc.nameToID(e)
+		return e.ChannelID
+	}
+
+	apply(`{"type":"channel_created","channel":{"id":"C1","name":"new-project","created":1360782804,"creator":"U11A2B8C1"}}`)
+	if resolve("new-project") != "C1" || sc.ChannelName("C1") != "new-project" {
+		t.Error("channel_created has not been applied")
+	}
+
+	e := apply(`{"type":"channel_rename","channel":{"id":"C11JBA78E","name":"slirc-renamed","created":1360782804}}`)
+	if e.PrevChannelname != "slirctest" || e.Chan() != "slirc-renamed" {
+		t.Errorf("channel_rename - expected: (slirctest -> slirc-renamed) - got: (%v -> %v)", e.PrevChannelname, e.Chan())
+	}
+	if resolve("slirc-renamed") != "C11JBA78E" || resolve("slirctest") != "" {
+		t.Error("channel_rename has not been applied")
+	}
+
+	apply(`{"type":"channel_archive","channel":"C1","user":"U11A2B8C1"}`)
+	if ch, _ := sc.LookupChannel("C1"); !ch.IsArchived {
+		t.Error("channel_archive has not been applied")
+	}
+	apply(`{"type":"channel_unarchive","channel":"C1","user":"U11A2B8C1"}`)
+	if ch, _ := sc.LookupChannel("C1"); ch.IsArchived {
+		t.Error("channel_unarchive has not been applied")
+	}
+	apply(`{"type":"channel_deleted","channel":"C1"}`)
+	if resolve("new-project") != "" {
+		t.Error("channel_deleted has not been applied")
+	}
+
+	joined := make(chan *Event, 1)
+	sc.HandleFunc("member_joined_channel", func(sc *Client, e *Event) { joined <- e })
+	if e := apply(`{"type":"member_joined_channel","user":"U11D00T0","channel":"G1","channel_type":"G"}`); e != nil {
+		t.Error("member_joined_channel of the bot has been dispatched before looking up the channel")
+	}
+	select {
+	case e := <-joined:
+		if e.Chan() != "secret-ops" || len(e.Raw) == 0 {
+			t.Errorf("member_joined_channel - expected: (secret-ops) with payload - got: (%v)", e.Chan())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("member_joined_channel has not been dispatched")
+	}
+	if resolve("secret-ops") != "G1" {
+		t.Error("private channel the bot joined has not been looked up")
+	}
+	apply(`{"type":"group_left","channel":"G1"}`)
+	if resolve("secret-ops") != "" {
+		t.Error("group_left has not been applied")
+	}
+}
+
+func TestDirectoryConcurrency(t *testing.T) {
+	sc := setup(t)
+	var apiResp APIResp
+	if err := json.Unmarshal(rawAPIResp, &apiResp); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	// writers: the readLoop applying events and a reconnect swapping the directory
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			sc.updateUser(&User{ID: "U11A2B8C1", Name: fmt.Sprintf("testorizor%d", i)})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			raw := fmt.Sprintf(`{"type":"channel_rename","channel":{"id":"C11JBA78E","name":"slirctest-%d"}}`, i)
+			var ce ChannelEvent
+			json.Unmarshal([]byte(raw), &ce)
+			if _, err := sc.applyChannelEvent(&ce); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			sc.bookKeeping(&apiResp)
+		}
+	}()
+	// readers: handler goroutines
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				e := &Event{UserID: "U11A2B8C1", ChannelID: "C11JBA78E", Text: "<@U11A2B8C1> in <#C11JBA78E>"}
+				sc.idToName(e)
+				sc.unSlackify("<@U11A2B8C1>")
+				sc.IsSelfMsg(e)
+				sc.UserIDByName("testorizor1")
+				sc.LookupChannelByName(e.Chan())
+				if len(sc.Users()) == 0 || len(sc.Channels()) == 0 {
+					t.Error("directory is empty")
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, ok := sc.LookupChannel("C11JBA78E"); !ok {
+		t.Error("channel lost after concurrent updates")
+	}
+}
@@ -0,0 +1,85 @@
+package slack
+
+import "sync"
+
+// dispatcher runs the handlers of events with the same key one event after the
+// other, in the order the events have been dispatched. Events with different keys
+// are handled concurrently by at most cap(sem) workers.
+type dispatcher struct {
+	sc    *Client
+	sem   chan struct{} // one token per running worker
+	slots chan struct{} // one token per queued event, dispatch drops events if none is left
+
+	mu     sync.Mutex
+	queues map[string][]*Event // pending events by key, present while a worker owns the key
+}
+
+func newDispatcher(sc *Client, workers, maxPending int) *dispatcher {
+	return &dispatcher{
+		sc:     sc,
+		sem:    make(chan struct{}, workers),
+		slots:  make(chan struct{}, maxPending),
+		queues: make(map[string][]*Event),
+	}
+}
+
+// dispatch queues event behind the pending events of its channel. If the handlers
+// have fallen behind, the event is dropped at once and reported to EventDropped,
+// so the read loop never waits for them.
+func (d *dispatcher) dispatch(key string, event *Event) {
+	select {
+	case d.slots <- struct{}{}:
+	default:
+		d.sc.logger.Warn("Handlers fell behind, dropping event", "side", "slack", "type", event.Type, "channel", key)
+		if d.sc.EventDropped != nil {
+			d.sc.EventDropped(event)
+		}
+		return
+	}
+	d.mu.Lock()
+	queue, active := d.queues[key]
+	d.queues[key] = append(queue, event)
+	d.mu.Unlock()
+	if !active {
+		go d.run(key)
+	}
+}
+
+// run handles the queued events of key until the queue is empty
+func (d *dispatcher) run(key string) {
+	d.sem <- struct{}{}
+	defer func() { <-d.sem }()
+	for {
+		d.mu.Lock()
+		queue := d.queues[key]
+		if len(queue) == 0 {
+			delete(d.queues, key)
+			d.mu.Unlock()
+			return
+		}
+		event := queue[0]
+		queue[0] = nil
+		d.queues[key] = queue[1:]
+		d.mu.Unlock()
+		<-d.slots
+
+		for _, handler := range d.sc.handlersFor(event.Type) {
+			d.sc.runHandler(handler, event)
+		}
+	}
+}
+
+// EnableOrderedDispatch makes the Client run the handlers of events in the same
+// channel sequentially and in order, on at most workers channels at a time.
+// If maxPending events are waiting, further events are dropped until handlers catch up
+// and reported to EventDropped. Events without a channel, like "connected", are still
+// handled concurrently. It must be called before Connect.
+func (sc *Client) EnableOrderedDispatch(workers, maxPending int) {
+	if workers < 1 {
+		workers = 1
+	}
+	if maxPending < 1 {
+		maxPending = 1
+	}
+	sc.dispatcher = newDispatcher(sc, workers, maxPending)
+}
@@ -0,0 +1,95 @@
+package slack
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOrderedDispatch(t *testing.T) {
+	sc := NewClient("foobar")
+	sc.EnableOrderedDispatch(2, 80)
+
+	var mu sync.Mutex
+	got := make(map[string][]string)
+	var running, maxRunning int32
+	var wg sync.WaitGroup
+	sc.HandleFunc("message", func(sc *Client, e *Event) {
+		defer wg.Done()
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+		// give later events the chance to overtake
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		got[e.ChannelID] = append(got[e.ChannelID], e.Text)
+		mu.Unlock()
+	})
+
+	channels := []string{"C1", "C2", "C3", "C4"}
+	for i := 0; i < 20; i++ {
+		for _, ch := range channels {
+			wg.Add(1)
+			sc.disPatchHandlers(&Event{Type: "message", ChannelID: ch, Text: fmt.Sprint(i)})
+		}
+	}
+	wg.Wait()
+
+	for _, ch := range channels {
+		if len(got[ch]) != 20 {
+			t.Fatalf("%s: expected 20 events, got %d", ch, len(got[ch]))
+		}
+		for i, text := range got[ch] {
+			if text != fmt.Sprint(i) {
+				t.Errorf("%s: event %d handled out of order: %v", ch, i, got[ch])
+				break
+			}
+		}
+	}
+	if maxRunning > 2 {
+		t.Errorf("expected at most 2 concurrent workers, got %d", maxRunning)
+	}
+}
+
+func TestOrderedDispatchDrop(t *testing.T) {
+	sc := NewClient("foobar")
+	sc.EnableOrderedDispatch(1, 1)
+	dropped := make(chan *Event, 1)
+	sc.EventDropped = func(e *Event) { dropped <- e }
+
+	release := make(chan struct{})
+	defer close(release)
+	sc.HandleFunc("message", func(sc *Client, e *Event) { <-release })
+
+	// the first event is being handled, the second is pending, the third is dropped
+	// without waiting for the handlers
+	for i := 0; i < 3; i++ {
+		start := time.Now()
+		sc.disPatchHandlers(&Event{Type: "message", ChannelID: "C1", Text: fmt.Sprint(i)})
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("dispatch blocked for %v", elapsed)
+		}
+		// let the worker pick up the first event
+		time.Sleep(5 * time.Millisecond)
+	}
+	select {
+	case e := <-dropped:
+		if e.Text != "2" {
+			t.Errorf("expected the third event to be dropped - got: %v", e.Text)
+		}
+	default:
+		t.Error("dispatch did not drop the event")
+	}
+	select {
+	case e := <-dropped:
+		t.Errorf("unexpected drop of event %v", e.Text)
+	default:
+	}
+}
@@ -1,6 +1,8 @@
 package slack
 
 import (
+	"encoding/json"
+	"strings"
 	"time"
 )
 
@@ -17,6 +19,19 @@ type Event struct {
 	SubType     string `json:"subtype,omitempty"`
 	Team        string `json:"team,omitempty"`
 	Ts          string `json:"ts,omitempty"`
+	FileID      string `json:"file_id,omitempty"` // file_shared, file_public, …
+	BotID       string `json:"bot_id,omitempty"`
+	BotName     string `json:"username,omitempty"` // name of a bot_message
+	// PrevChannelname is the previous name of a renamed channel (channel_rename, group_rename)
+	PrevChannelname string `json:"-"`
+
+	Attachments []Attachment `json:"attachments,omitempty"`
+	Blocks      []Block      `json:"blocks,omitempty"`
+
+	// Raw is the payload as received from Slack, see Typed
+	Raw json.RawMessage `json:"-"`
+
+	acked chan<- ackResult // receives slack's acknowledgement, see SendContext
 }
 
 // UserEvent carries a UserProfile instead of a UserID under the `user` key (in contrast to Event)
@@ -49,6 +64,11 @@ func (se *Event) Chan() string {
 	return se.Channelname
 }
 
+// IsIM reports whether the event happened in a direct message channel
+func (se *Event) IsIM() bool {
+	return strings.HasPrefix(se.ChannelID, "D")
+}
+
 type User struct {
 	ID       string    `json:"id"`
 	Name     string    `json:"name"`
@@ -70,10 +90,17 @@ type Channel struct {
 	ID         string `json:"id"`
 	Name       string `json:"name"`
 	IsChannel  bool   `json:"is_channel"`
+	IsGroup    bool   `json:"is_group"`
 	Creator    string `json:"creator"`
 	IsArchived bool   `json:"is_archived"`
 }
 
+// IM is a direct message channel between the bot and a user
+type IM struct {
+	ID   string `json:"id"`
+	User string `json:"user"`
+}
+
 type Error struct {
 	Code int    `json:"code,omitempty"`
 	Msg  string `json:"msg,omitempty"`
@@ -81,16 +108,19 @@ type Error struct {
 
 func (sc *Client) idToName(e *Event) {
 
-	channel, ok := sc.chanIDMap[e.ChannelID]
+	channel, ok := sc.dir.channel(e.ChannelID)
 	if ok {
 		e.Channelname = channel.Name
 	}
 	e.Username = sc.nickForUserID(e.UserID)
+	if e.UserID == "" && e.BotName != "" {
+		e.Username = e.BotName
+	}
 }
 
 func (sc *Client) nameToID(e *Event) {
 	// we only have to convert the channel, since user will be our slackbot anyway
-	channel, ok := sc.chanMap[e.Channelname]
+	channel, ok := sc.dir.channelByName(e.Channelname)
 	if ok {
 		e.ChannelID = channel.ID
 	}
@@ -98,5 +128,27 @@ func (sc *Client) nameToID(e *Event) {
 }
 
 func (sc *Client) IsSelfMsg(event *Event) bool {
-	return event.UserID == sc.self.ID
+	return event.UserID != "" && event.UserID == sc.dir.getSelf().ID
+}
+
+// bridgeMarker is appended invisibly to the messages a slirc Client relays, so that
+// other bridges in the same channel can recognise messages that have already been relayed
+const bridgeMarker = "\u2063\u200b\u2063"
+
+// IsBridged reports whether event has been sent by a slirc bridge, this one or another instance
+func IsBridged(event *Event) bool {
+	return strings.HasSuffix(event.Text, bridgeMarker)
+}
+
+// markBridged appends the bridgeMarker to text, see Relay
+func markBridged(text string) string {
+	if strings.HasSuffix(text, bridgeMarker) {
+		return text
+	}
+	return text + bridgeMarker
+}
+
+// IsBot reports whether event has been posted by a bot or an integration
+func (se *Event) IsBot() bool {
+	return se.BotID != "" || se.SubType == "bot_message"
 }
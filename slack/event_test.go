@@ -1,7 +1,9 @@
 package slack
 
 import (
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestEvent(t *testing.T) {
@@ -55,3 +57,75 @@ func TestEvent(t *testing.T) {
 	}
 
 }
+
+func TestIMAndUserLookup(t *testing.T) {
+	sc := setup(t)
+
+	if !(&Event{ChannelID: "D024BE91L"}).IsIM() || (&Event{ChannelID: "C11JBA78E"}).IsIM() {
+		t.Error("IsIM produced wrong result")
+	}
+
+	for _, name := range []string{"testorizor1", "@Tester1"} {
+		if userID, ok := sc.UserIDByName(name); !ok || userID != "U11A2B8C1" {
+			t.Errorf("UserIDByName(%v) - expected: (U11A2B8C1) - got: (%v)", name, userID)
+		}
+	}
+	if _, ok := sc.UserIDByName("nobody"); ok {
+		t.Error("UserIDByName found an unknown user")
+	}
+}
+
+func TestBridgeMarker(t *testing.T) {
+	sc := setup(t)
+	sc.Relay("slirctest", "hello")
+	sent := sc.queue.pop()
+	if !IsBridged(sent) || strings.TrimSuffix(sent.Text, bridgeMarker) != "hello" {
+		t.Errorf("Relay - expected a marked message - got: (%q)", sent.Text)
+	}
+	sc.Send("slirctest", "hello")
+	if sent := sc.queue.pop(); IsBridged(sent) || sent.Text != "hello" {
+		t.Errorf("Send - expected an unmarked message - got: (%q)", sent.Text)
+	}
+	if IsBridged(&Event{Text: "hello"}) {
+		t.Error("IsBridged produced wrong result")
+	}
+
+	bot := &Event{SubType: "bot_message", BotID: "B1", BotName: "CI"}
+	sc.idToName(bot)
+	if !bot.IsBot() || bot.Usernick() != "CI" {
+		t.Errorf("bot message - expected: (CI) - got: (%v)", bot.Usernick())
+	}
+}
+
+func TestBridgeMarkerRoundTrip(t *testing.T) {
+	srv := newRTMStandIn(t)
+	defer srv.Close()
+
+	sc := NewClient("foobar")
+	sc.APIURL = srv.URL + "/"
+	echoes := make(chan *Event, 2)
+	sc.HandleFunc("message", func(sc *Client, e *Event) { echoes <- e })
+	if err := sc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer sc.Close()
+
+	sc.Relay("general", "relayed &amp; marked")
+	sc.Send("general", "reply")
+	// handlers run concurrently, so the echoes may arrive in any order
+	bridged := make(map[string]bool)
+	for len(bridged) < 2 {
+		select {
+		case e := <-echoes:
+			bridged[strings.TrimSuffix(e.Text, bridgeMarker)] = IsBridged(e)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("missing echoes - got: %v", bridged)
+		}
+	}
+	if b, ok := bridged["relayed & marked"]; !ok || !b {
+		t.Errorf("relayed message - expected a marked echo - got: %v", bridged)
+	}
+	if b, ok := bridged["reply"]; !ok || b {
+		t.Errorf("sent message - expected an unmarked echo - got: %v", bridged)
+	}
+}
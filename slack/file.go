@@ -1,12 +1,12 @@
 package slack
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"log"
+	"io"
 	"net/http"
+	"net/url"
 )
 
 type FileApiResp struct {
@@ -16,55 +16,68 @@ type FileApiResp struct {
 }
 
 type File struct {
-	ID       string   `json:"id"` // Every event should have a unique (for that connection) positive integer ID.
-	UserID   string   `json:"user,omitempty"`
-	PubPerma string   `json:"permalink_public,omitempty"`
-	Channels []string `json:"channels,omitempty"`
+	ID          string   `json:"id"` // Every event should have a unique (for that connection) positive integer ID.
+	UserID      string   `json:"user,omitempty"`
+	Name        string   `json:"name,omitempty"`
+	Title       string   `json:"title,omitempty"`
+	Mimetype    string   `json:"mimetype,omitempty"`
+	Size        int64    `json:"size,omitempty"`
+	URLPrivate  string   `json:"url_private_download,omitempty"`
+	PubPerma    string   `json:"permalink_public,omitempty"`
+	Channels    []string `json:"channels,omitempty"`
+	Groups      []string `json:"groups,omitempty"`
+	IsPublic    bool     `json:"is_public,omitempty"`
+	PublicShare bool     `json:"public_url_shared,omitempty"`
 }
 
-func (sc *Client) shareFile(fileID string) {
-	sc.sharemu.Lock()
-	defer sc.sharemu.Unlock()
-	done, ok := sc.shared[fileID]
-	if done || ok {
-		return
-	}
-	const fileSharePublicURL = ""
-
-	// Enable public sharing URL
-	client := &http.Client{}
-	payload := []byte(fmt.Sprintf(`{"token": "%s", "file": "%s"}`, sc.UserToken, fileID))
+// ErrFileTooLarge is returned by DownloadFile if the file exceeds the size limit
+var ErrFileTooLarge = errors.New("file too large")
 
-	req, err := http.NewRequest("POST", "https://slack.com/api/files.sharedPublicURL", bytes.NewBuffer(payload))
+// DownloadFile writes the content of f to w, authenticated by the bot token.
+// If maxSize is positive, files larger than maxSize bytes fail with ErrFileTooLarge.
+func (sc *Client) DownloadFile(f *File, w io.Writer, maxSize int64) error {
+	if maxSize > 0 && f.Size > maxSize {
+		return ErrFileTooLarge
+	}
+	req, err := http.NewRequest("GET", f.URLPrivate, nil)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	req.Header.Set("charset", "UTF-8")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", sc.UserToken))
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", sc.BotToken))
 
-	resp, err := client.Do(req)
+	resp, err := sc.httpClient.Do(req)
 	if err != nil {
-		log.Println(err)
-		return
+		return fmt.Errorf("Failed to download file %s: %v", f.ID, err)
 	}
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("Failed to read files.sharedPublicURL API response: %v", err)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Failed to download file %s: %s", f.ID, resp.Status)
 	}
 
-	var f FileApiResp
-	if err = json.Unmarshal(body, &f); err != nil {
-		log.Println(err)
-		return
+	var r io.Reader = resp.Body
+	if maxSize > 0 {
+		// read one byte more than allowed to detect oversized files
+		r = io.LimitReader(resp.Body, maxSize+1)
 	}
+	n, err := io.Copy(w, r)
+	if err != nil {
+		return fmt.Errorf("Failed to download file %s: %v", f.ID, err)
+	}
+	if maxSize > 0 && n > maxSize {
+		return ErrFileTooLarge
+	}
+	return nil
+}
 
-	if !f.OK {
-		log.Println("Image sharing failed:")
-		if f.Error != "" {
-			log.Println(f.Error)
-		}
-		return
+// shareFile makes the file with fileID public and announces its public link
+func (sc *Client) shareFile(fileID string) error {
+	// Enable public sharing URL
+	f, err := sc.userFileAPI("files.sharedPublicURL", fileID)
+	if err != nil {
+		return err
+	}
+	if sc.FilePublished != nil {
+		sc.FilePublished(f.File)
 	}
 	if len(f.File.Channels) > 0 {
 
@@ -75,5 +88,30 @@ func (sc *Client) shareFile(fileID string) {
 			sc.disPatchHandlers(event)
 		}
 	}
-	sc.shared[fileID] = true
+	return nil
+}
+
+// RevokePublicURL disables the public link of the file with fileID. Requires UserToken.
+// See https://api.slack.com/methods/files.revokePublicURL
+func (sc *Client) RevokePublicURL(fileID string) error {
+	if sc.UserToken == "" {
+		return errors.New("files.revokePublicURL requires a user token")
+	}
+	if _, err := sc.userFileAPI("files.revokePublicURL", fileID); err != nil {
+		return err
+	}
+	sc.shares.forget(fileID)
+	return nil
+}
+
+// userFileAPI calls a files.* method that operates on fileID with the user token
+func (sc *Client) userFileAPI(method, fileID string) (*FileApiResp, error) {
+	var f FileApiResp
+	if err := sc.callWithToken(context.Background(), sc.UserToken, method, url.Values{"file": {fileID}}, &f); err != nil {
+		return nil, err
+	}
+	if f.File == nil {
+		f.File = &File{ID: fileID}
+	}
+	return &f, nil
 }
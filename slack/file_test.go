@@ -0,0 +1,38 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFileInfo(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/files.info" || r.Header.Get("Authorization") != "Bearer foobar" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.FormValue("file") != "F1" {
+			fmt.Fprint(w, `{"ok":false,"error":"file_not_found"}`)
+			return
+		}
+		fmt.Fprint(w, `{"ok":true,"file":{"id":"F1","name":"cat.png","mimetype":"image/png","size":42,"url_private_download":"https://files.slack.com/F1/cat.png","channels":["C03JAPEHJ"]}}`)
+	}))
+	defer api.Close()
+
+	sc := NewClient("foobar")
+	sc.APIURL = api.URL + "/"
+
+	f, err := sc.FileInfo(context.Background(), "F1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Name != "cat.png" || f.Mimetype != "image/png" || f.Size != 42 || f.URLPrivate == "" || len(f.Channels) != 1 {
+		t.Errorf("FileInfo - unexpected file: %+v", f)
+	}
+	if _, err := sc.FileInfo(context.Background(), "F2"); !IsAPIError(err, "file_not_found") {
+		t.Errorf("FileInfo - expected file_not_found for an unknown file - got: %v", err)
+	}
+}
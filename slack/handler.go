@@ -0,0 +1,98 @@
+package slack
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// AllEvents registers a handler for every event type
+const AllEvents = "*"
+
+type HandlerFunc func(*Client, *Event)
+
+// Middleware wraps every handler, e.g. for logging, metrics or filtering.
+// A Middleware may drop an event by not calling next.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Registration is a handler registered with HandleFunc
+type Registration struct {
+	sc      *Client
+	msgType string
+	hf      HandlerFunc
+}
+
+// HandleFunc registers hf for events of msgType, or for all events if msgType is AllEvents.
+// The handler can be removed with the returned Registration.
+func (sc *Client) HandleFunc(msgType string, hf HandlerFunc) *Registration {
+	reg := &Registration{sc: sc, msgType: msgType, hf: hf}
+	sc.hmu.Lock()
+	sc.handlers[msgType] = append(sc.handlers[msgType], reg)
+	sc.hmu.Unlock()
+	return reg
+}
+
+// Remove unregisters the handler. Events already being dispatched may still reach it.
+func (reg *Registration) Remove() {
+	sc := reg.sc
+	sc.hmu.Lock()
+	defer sc.hmu.Unlock()
+	regs := sc.handlers[reg.msgType]
+	for i, r := range regs {
+		if r == reg {
+			// copy, handlersFor snapshots may still refer to the old slice
+			sc.handlers[reg.msgType] = append(regs[:i:i], regs[i+1:]...)
+			return
+		}
+	}
+}
+
+// Use adds middleware to the chain that wraps every handler, including those
+// registered before. The middleware added first is the outermost.
+func (sc *Client) Use(mw ...Middleware) {
+	sc.hmu.Lock()
+	sc.middleware = append(sc.middleware, mw...)
+	sc.hmu.Unlock()
+}
+
+// handlersFor returns the handlers for eventType, wrapped with the middleware
+func (sc *Client) handlersFor(eventType string) []HandlerFunc {
+	sc.hmu.RLock()
+	defer sc.hmu.RUnlock()
+	regs := sc.handlers[eventType]
+	if eventType != AllEvents {
+		regs = append(regs[:len(regs):len(regs)], sc.handlers[AllEvents]...)
+	}
+	handlers := make([]HandlerFunc, len(regs))
+	for i, reg := range regs {
+		hf := reg.hf
+		for j := len(sc.middleware) - 1; j >= 0; j-- {
+			hf = sc.middleware[j](hf)
+		}
+		handlers[i] = hf
+	}
+	return handlers
+}
+
+// runHandler calls hf and recovers from panics, so that a broken handler cannot take down the Client
+func (sc *Client) runHandler(hf HandlerFunc, event *Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			sc.logger.Error("Handler panicked", "side", "slack", "type", event.Type, "panic", fmt.Sprint(r), "stack", string(debug.Stack()))
+		}
+	}()
+	hf(sc, event)
+}
+
+func (sc *Client) disPatchHandlers(event *Event) {
+	handlers := sc.handlersFor(event.Type)
+	if len(handlers) == 0 {
+		return
+	}
+	if sc.dispatcher != nil && event.ChannelID != "" {
+		sc.dispatcher.dispatch(event.ChannelID, event)
+		return
+	}
+	for _, handler := range handlers {
+		go sc.runHandler(handler, event)
+	}
+}
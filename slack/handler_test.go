@@ -0,0 +1,95 @@
+package slack
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// syncBuffer is a bytes.Buffer that handler goroutines can log to
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestHandlers(t *testing.T) {
+	sc := NewClient("foobar")
+	var logs syncBuffer
+	sc.SetLogger(NewStdLogger(log.New(&logs, "", 0), false))
+	sc.EnableOrderedDispatch(1, 8)
+
+	var mu sync.Mutex
+	var calls []string
+	record := func(name string) HandlerFunc {
+		return func(sc *Client, e *Event) {
+			if e.Type == "done" {
+				return
+			}
+			mu.Lock()
+			calls = append(calls, name+":"+e.Type)
+			mu.Unlock()
+		}
+	}
+	done := make(chan struct{})
+	dispatch := func(eventType string) []string {
+		t.Helper()
+		calls = nil
+		sc.disPatchHandlers(&Event{Type: eventType, ChannelID: "C1"})
+		sc.disPatchHandlers(&Event{Type: "done", ChannelID: "C1"})
+		<-done
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), calls...)
+	}
+	msg := sc.HandleFunc("message", record("msg"))
+	sc.HandleFunc(AllEvents, record("all"))
+	broken := sc.HandleFunc("message", func(*Client, *Event) { panic("broken handler") })
+	// ordered dispatch runs it after all handlers of the previous event in C1
+	sc.HandleFunc("done", func(*Client, *Event) { done <- struct{}{} })
+	if got := strings.Join(dispatch("message"), " "); got != "msg:message all:message" {
+		t.Errorf("handlers - got: %q", got)
+	}
+	if !strings.Contains(logs.String(), "broken handler") {
+		t.Errorf("panic has not been logged: %q", logs.String())
+	}
+
+	msg.Remove()
+	msg.Remove()
+	broken.Remove()
+	if got := strings.Join(dispatch("message"), " "); got != "all:message" {
+		t.Errorf("after Remove - got: %q", got)
+	}
+
+	// middleware applies to handlers registered before, the first is the outermost
+	wrap := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(sc *Client, e *Event) {
+				record(name)(sc, e)
+				if e.Type != "dropped" {
+					next(sc, e)
+				}
+			}
+		}
+	}
+	sc.Use(wrap("outer"), wrap("inner"))
+	if got := strings.Join(dispatch("message"), " "); got != "outer:message inner:message all:message" {
+		t.Errorf("middleware - got: %q", got)
+	}
+	if got := strings.Join(dispatch("dropped"), " "); got != "outer:dropped" {
+		t.Errorf("middleware dropping the event - got: %q", got)
+	}
+}
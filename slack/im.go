@@ -0,0 +1,54 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SendIM sends msg as a direct message from the bot to the user with userID
+func (sc *Client) SendIM(userID, msg string) error {
+	imID, err := sc.imForUser(userID)
+	if err != nil {
+		return err
+	}
+	sc.send(&Event{Type: "message", ChannelID: imID, Text: msg})
+	return nil
+}
+
+// UserIDByName returns the ID of the user whose name or display name is name (case-insensitive)
+func (sc *Client) UserIDByName(name string) (userID string, ok bool) {
+	name = strings.TrimPrefix(name, "@")
+	for _, user := range sc.dir.allUsers() {
+		if user.Deleted {
+			continue
+		}
+		if strings.EqualFold(user.Name, name) || strings.EqualFold(user.Profile.DisplayName, name) {
+			return user.ID, true
+		}
+	}
+	return "", false
+}
+
+// imForUser returns the ID of the IM channel with userID, opening one if necessary
+func (sc *Client) imForUser(userID string) (string, error) {
+	sc.immu.Lock()
+	imID, ok := sc.imIDMap[userID]
+	sc.immu.Unlock()
+	if ok {
+		return imID, nil
+	}
+
+	imID, err := sc.openConversation(context.Background(), userID)
+	if err != nil {
+		return "", fmt.Errorf("Failed to open IM: %v", err)
+	}
+
+	sc.immu.Lock()
+	if sc.imIDMap == nil {
+		sc.imIDMap = make(map[string]string)
+	}
+	sc.imIDMap[userID] = imID
+	sc.immu.Unlock()
+	return imID, nil
+}
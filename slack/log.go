@@ -0,0 +1,120 @@
+package slack
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// Logger is used for all log output of the Client and the bridge.
+// keyvals are alternating keys and values, e.g. "side", "slack", "channel", "general"
+type Logger interface {
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Warn(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+}
+
+// stdLogger writes logfmt-style lines through the standard log package
+type stdLogger struct {
+	l     *log.Logger
+	debug bool
+}
+
+// NewStdLogger returns a Logger writing to l, or to the standard logger if l is nil.
+// Debug messages are only written if debug is true.
+func NewStdLogger(l *log.Logger, debug bool) Logger {
+	return &stdLogger{l: l, debug: debug}
+}
+
+func (sl *stdLogger) Debug(msg string, keyvals ...interface{}) {
+	if sl.debug {
+		sl.output("DEBUG", msg, keyvals)
+	}
+}
+
+func (sl *stdLogger) Info(msg string, keyvals ...interface{}) {
+	sl.output("INFO", msg, keyvals)
+}
+
+func (sl *stdLogger) Warn(msg string, keyvals ...interface{}) {
+	sl.output("WARN", msg, keyvals)
+}
+
+func (sl *stdLogger) Error(msg string, keyvals ...interface{}) {
+	sl.output("ERROR", msg, keyvals)
+}
+
+func (sl *stdLogger) output(level, msg string, keyvals []interface{}) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%-5s %s", level, msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		var val interface{} = "MISSING"
+		if i+1 < len(keyvals) {
+			val = keyvals[i+1]
+		}
+		fmt.Fprintf(&buf, " %v=%q", keyvals[i], fmt.Sprint(val))
+	}
+	if sl.l == nil {
+		log.Output(3, buf.String())
+		return
+	}
+	sl.l.Output(3, buf.String())
+}
+
+// tokenRe matches Slack tokens, e.g. xoxb-…, xoxp-…
+var tokenRe = regexp.MustCompile(`xox[abposr]-[0-9A-Za-z-]+`)
+
+// redactLogger replaces tokens and secrets in messages and values before passing them on
+type redactLogger struct {
+	next    Logger
+	secrets []string
+}
+
+// RedactLogger wraps next so that Slack tokens and the given secrets never reach the log output.
+func RedactLogger(next Logger, secrets ...string) Logger {
+	if rl, ok := next.(*redactLogger); ok {
+		next = rl.next
+		secrets = append(rl.secrets, secrets...)
+	}
+	var nonEmpty []string
+	for _, secret := range secrets {
+		if secret != "" {
+			nonEmpty = append(nonEmpty, secret)
+		}
+	}
+	return &redactLogger{next: next, secrets: nonEmpty}
+}
+
+func (rl *redactLogger) redact(str string) string {
+	for _, secret := range rl.secrets {
+		str = strings.Replace(str, secret, "[redacted]", -1)
+	}
+	return tokenRe.ReplaceAllString(str, "[redacted]")
+}
+
+func (rl *redactLogger) redactAll(keyvals []interface{}) []interface{} {
+	redacted := make([]interface{}, len(keyvals))
+	for i, kv := range keyvals {
+		redacted[i] = rl.redact(fmt.Sprint(kv))
+	}
+	return redacted
+}
+
+func (rl *redactLogger) Debug(msg string, keyvals ...interface{}) {
+	rl.next.Debug(rl.redact(msg), rl.redactAll(keyvals)...)
+}
+
+func (rl *redactLogger) Info(msg string, keyvals ...interface{}) {
+	rl.next.Info(rl.redact(msg), rl.redactAll(keyvals)...)
+}
+
+func (rl *redactLogger) Warn(msg string, keyvals ...interface{}) {
+	rl.next.Warn(rl.redact(msg), rl.redactAll(keyvals)...)
+}
+
+func (rl *redactLogger) Error(msg string, keyvals ...interface{}) {
+	rl.next.Error(rl.redact(msg), rl.redactAll(keyvals)...)
+}
@@ -0,0 +1,124 @@
+package slack
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// socks5StandIn is a minimal SOCKS5 proxy (no auth, CONNECT only) counting its connections
+type socks5StandIn struct {
+	ln       net.Listener
+	connects int32
+}
+
+func newSOCKS5StandIn(t *testing.T) *socks5StandIn {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &socks5StandIn{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *socks5StandIn) URL() string {
+	return "socks5://" + s.ln.Addr().String()
+}
+
+func (s *socks5StandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	// greeting: version, number of methods, methods
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(conn, make([]byte, head[1])); err != nil {
+		return
+	}
+	conn.Write([]byte{5, 0})
+
+	// request: version, command, reserved, address type
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(conn, req); err != nil || req[1] != 1 {
+		return
+	}
+	var host string
+	switch req[3] {
+	case 1:
+		ip := make([]byte, 4)
+		io.ReadFull(conn, ip)
+		host = net.IP(ip).String()
+	case 3:
+		l := make([]byte, 1)
+		io.ReadFull(conn, l)
+		name := make([]byte, l[0])
+		io.ReadFull(conn, name)
+		host = string(name)
+	default:
+		return
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return
+	}
+	target, err := net.Dial("tcp", net.JoinHostPort(host, fmt.Sprint(binary.BigEndian.Uint16(port))))
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer target.Close()
+	atomic.AddInt32(&s.connects, 1)
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+	go io.Copy(target, conn)
+	io.Copy(conn, target)
+}
+
+func TestSetProxy(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rtm.start" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"ok":false,"error":"invalid_auth"}`)
+	}))
+	defer api.Close()
+
+	proxy := newSOCKS5StandIn(t)
+	defer proxy.ln.Close()
+
+	sc := NewClient("foobar")
+	sc.APIURL = api.URL + "/"
+	if err := sc.SetProxy(proxy.URL()); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := sc.startRTM()
+	if err == nil || !strings.Contains(err.Error(), "invalid_auth") {
+		t.Errorf("startRTM - expected invalid_auth error - got: (%v)", err)
+	}
+	if atomic.LoadInt32(&proxy.connects) != 1 {
+		t.Errorf("expected 1 connection through the proxy, got %v", proxy.connects)
+	}
+
+	for _, invalid := range []string{"ftp://proxy.example.org", "https://proxy.example.org:3128", "socks5://"} {
+		if err := sc.SetProxy(invalid); err == nil {
+			t.Errorf("SetProxy accepted %q", invalid)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package slack
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// OverflowPolicy decides what happens to messages sent while the outbound queue is full
+type OverflowPolicy int
+
+const (
+	// QueueDropOldest discards the oldest queued message to make room
+	QueueDropOldest OverflowPolicy = iota
+	// QueueDropNewest discards the message being sent
+	QueueDropNewest
+	// QueueBlock makes SendContext wait for room until its context is done.
+	// Send, which has no context, drops the message instead.
+	QueueBlock
+)
+
+// DefaultQueueSize is the capacity of the outbound queue unless changed with SetOutboundQueue
+const DefaultQueueSize = 256
+
+// ErrQueueFull is returned for messages dropped because the outbound queue is full
+var ErrQueueFull = errors.New("outbound queue full")
+
+// outQueue holds the events waiting to be written to the websocket. It lives as long
+// as the Client, so events queued while slack is unreachable are sent after reconnecting.
+type outQueue struct {
+	mu       sync.Mutex
+	events   []*Event
+	capacity int
+	policy   OverflowPolicy
+	ready    chan struct{} // signalled when events are queued
+	space    chan struct{} // signalled when events are taken
+}
+
+func newOutQueue(capacity int, policy OverflowPolicy) *outQueue {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &outQueue{
+		capacity: capacity,
+		policy:   policy,
+		ready:    make(chan struct{}, 1),
+		space:    make(chan struct{}, 1),
+	}
+}
+
+func signal(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+// push queues event according to the overflow policy. QueueBlock only waits if wait is set.
+// It returns the event dropped to make room, if any.
+func (q *outQueue) push(ctx context.Context, event *Event, wait bool) (dropped *Event, err error) {
+	for {
+		q.mu.Lock()
+		if len(q.events) < q.capacity {
+			q.events = append(q.events, event)
+			q.mu.Unlock()
+			signal(q.ready)
+			return nil, nil
+		}
+		switch {
+		case q.policy == QueueDropOldest:
+			dropped = q.events[0]
+			q.events[0] = nil
+			q.events = append(q.events[1:], event)
+			q.mu.Unlock()
+			signal(q.ready)
+			return dropped, nil
+		case q.policy == QueueDropNewest || !wait:
+			q.mu.Unlock()
+			return nil, ErrQueueFull
+		}
+		q.mu.Unlock()
+		select {
+		case <-q.space:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// pop takes the oldest event, or returns nil if the queue is empty
+func (q *outQueue) pop() *Event {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.events) == 0 {
+		return nil
+	}
+	event := q.events[0]
+	q.events[0] = nil
+	q.events = q.events[1:]
+	if len(q.events) > 0 {
+		signal(q.ready)
+	}
+	signal(q.space)
+	return event
+}
+
+// requeue puts back an event that could not be written, ahead of all others.
+// If the queue filled up in the meantime, the overflow policy decides which event
+// is dropped: the requeued event is the oldest, QueueDropNewest and QueueBlock
+// drop the newest one. It returns the dropped event, if any.
+func (q *outQueue) requeue(event *Event) (dropped *Event) {
+	q.mu.Lock()
+	if len(q.events) >= q.capacity {
+		if q.policy == QueueDropOldest {
+			q.mu.Unlock()
+			return event
+		}
+		last := len(q.events) - 1
+		dropped = q.events[last]
+		q.events[last] = nil
+		q.events = q.events[:last]
+	}
+	q.events = append([]*Event{event}, q.events...)
+	q.mu.Unlock()
+	signal(q.ready)
+	return dropped
+}
+
+func (q *outQueue) depth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.events)
+}
+
+// SetOutboundQueue sets the capacity and overflow policy of the queue of messages
+// waiting to be sent. It must be called before the first message is sent.
+func (sc *Client) SetOutboundQueue(capacity int, policy OverflowPolicy) {
+	sc.queue = newOutQueue(capacity, policy)
+}
+
+// QueueDepth returns the number of messages waiting to be sent
+func (sc *Client) QueueDepth() int {
+	return sc.queue.depth()
+}
+
+// enqueue queues event for the writeLoop. With the QueueBlock policy it waits for room if wait is set.
+func (sc *Client) enqueue(ctx context.Context, event *Event, wait bool) error {
+	dropped, err := sc.queue.push(ctx, event, wait)
+	if err == ErrQueueFull {
+		sc.logger.Warn("Outbound queue full, dropping message", "side", "slack", "channel", event.Chan(), "type", event.Type)
+	}
+	if dropped != nil {
+		sc.dropped(dropped)
+	}
+	return err
+}
+
+// requeue queues an event that could not be written again, see outQueue.requeue
+func (sc *Client) requeue(event *Event) {
+	if dropped := sc.queue.requeue(event); dropped != nil {
+		sc.dropped(dropped)
+	}
+}
+
+// dropped fails an event that has been dropped from the full queue
+func (sc *Client) dropped(event *Event) {
+	sc.logger.Warn("Outbound queue full, dropping message", "side", "slack", "channel", event.Chan(), "type", event.Type)
+	if event.acked != nil {
+		event.acked <- ackResult{err: ErrQueueFull}
+	}
+}
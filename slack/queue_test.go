@@ -0,0 +1,154 @@
+package slack
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestOutQueue(t *testing.T) {
+	ctx := context.Background()
+	msg := func(text string) *Event { return &Event{Type: "message", ChannelID: "C1", Text: text} }
+
+	sc := NewClient("foobar")
+	sc.SetOutboundQueue(2, QueueDropOldest)
+	acked := make(chan ackResult, 1)
+	oldest := msg("1")
+	oldest.acked = acked
+	for _, e := range []*Event{oldest, msg("2"), msg("3")} {
+		if err := sc.enqueue(ctx, e, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if res := <-acked; res.err != ErrQueueFull {
+		t.Errorf("dropped message - expected ErrQueueFull - got: %v", res.err)
+	}
+	if sc.QueueDepth() != 2 || sc.queue.pop().Text != "2" {
+		t.Error("QueueDropOldest has not dropped the oldest message")
+	}
+
+	sc.SetOutboundQueue(1, QueueDropNewest)
+	sc.Send("C1", "1")
+	if err := sc.enqueue(ctx, msg("2"), true); err != ErrQueueFull {
+		t.Errorf("QueueDropNewest - expected ErrQueueFull - got: %v", err)
+	}
+
+	sc.SetOutboundQueue(1, QueueBlock)
+	sc.Send("C1", "1")
+	// Send must not block while slack is unreachable
+	done := make(chan struct{})
+	go func() {
+		sc.Send("C1", "2")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Send blocked on a full queue")
+	}
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := sc.enqueue(short, msg("2"), true); err != context.DeadlineExceeded {
+		t.Errorf("QueueBlock - expected context.DeadlineExceeded - got: %v", err)
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		sc.queue.pop()
+	}()
+	if err := sc.enqueue(ctx, msg("3"), true); err != nil || sc.QueueDepth() != 1 {
+		t.Errorf("QueueBlock - expected to wait for room - got: (%v, depth %d)", err, sc.QueueDepth())
+	}
+}
+
+func TestRequeue(t *testing.T) {
+	msg := func(text string) *Event { return &Event{Type: "message", ChannelID: "C1", Text: text} }
+
+	q := newOutQueue(2, QueueDropOldest)
+	q.push(context.Background(), msg("2"), false)
+	q.push(context.Background(), msg("3"), false)
+	if dropped := q.requeue(msg("1")); dropped == nil || dropped.Text != "1" || q.depth() != 2 {
+		t.Errorf("QueueDropOldest - expected the requeued message to be dropped - got: (%v, depth %d)", dropped, q.depth())
+	}
+
+	q = newOutQueue(2, QueueDropNewest)
+	q.push(context.Background(), msg("2"), false)
+	q.push(context.Background(), msg("3"), false)
+	if dropped := q.requeue(msg("1")); dropped == nil || dropped.Text != "3" || q.depth() != 2 {
+		t.Errorf("QueueDropNewest - expected the newest message to be dropped - got: (%v, depth %d)", dropped, q.depth())
+	}
+	if first := q.pop(); first.Text != "1" {
+		t.Errorf("requeued message is not sent first - got: %v", first.Text)
+	}
+}
+
+func TestWritePacing(t *testing.T) {
+	srv := newRTMStandIn(t)
+	defer srv.Close()
+
+	sc := NewClient("foobar")
+	sc.APIURL = srv.URL + "/"
+	// an empty bucket refilling every 100ms
+	rl := newRateLimiter(600)
+	rl.tokens = 0
+	sc.rtmLimit = rl
+	if err := sc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer sc.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	results := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			_, err := sc.SendContext(ctx, "C1", "paced")
+			results <- err
+		}()
+	}
+	for i := 0; i < 3; i++ {
+		if err := <-results; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Errorf("3 messages written in %v, expected them to be paced", elapsed)
+	}
+	sc.limitmu.Lock()
+	_, shared := sc.limiters["chat.postMessage"]
+	sc.limitmu.Unlock()
+	if shared {
+		t.Error("RTM messages have been paced by the chat.postMessage limiter")
+	}
+}
+
+func TestQueueAcrossReconnect(t *testing.T) {
+	srv := newRTMStandIn(t)
+	defer srv.Close()
+
+	sc := NewClient("foobar")
+	sc.APIURL = srv.URL + "/"
+
+	// queued while not connected
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result := make(chan error, 1)
+	go func() {
+		_, err := sc.SendContext(ctx, "C1", "queued")
+		result <- err
+	}()
+	for sc.QueueDepth() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := sc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer sc.Close()
+	if err := <-result; err != nil {
+		t.Errorf("queued message - got: %v", err)
+	}
+	if sc.QueueDepth() != 0 {
+		t.Errorf("expected an empty queue - got: %d", sc.QueueDepth())
+	}
+}
@@ -0,0 +1,203 @@
+package slack
+
+import (
+	"html"
+	"strings"
+)
+
+// maxRichLines is the number of lines Render produces at most for attachments and blocks
+const maxRichLines = 8
+
+// Attachment is a legacy message attachment, as sent by many integrations
+// See https://api.slack.com/reference/messaging/attachments
+type Attachment struct {
+	Fallback  string            `json:"fallback,omitempty"`
+	Pretext   string            `json:"pretext,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	TitleLink string            `json:"title_link,omitempty"`
+	Text      string            `json:"text,omitempty"`
+	Fields    []AttachmentField `json:"fields,omitempty"`
+}
+
+type AttachmentField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short,omitempty"`
+}
+
+// Block is a Block Kit layout block. Only the parts needed to render
+// section, header, context and rich_text blocks are decoded.
+// See https://api.slack.com/reference/block-kit/blocks
+type Block struct {
+	Type     string         `json:"type"`
+	Text     *BlockText     `json:"text,omitempty"`
+	Fields   []BlockText    `json:"fields,omitempty"`
+	Elements []BlockElement `json:"elements,omitempty"`
+}
+
+// BlockText is a text object, either plain_text or mrkdwn
+type BlockText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// BlockElement is an element of a context or rich_text block; rich text
+// containers (sections, lists, quotes) carry further elements
+type BlockElement struct {
+	Type      string         `json:"type"`
+	Text      string         `json:"text,omitempty"`
+	URL       string         `json:"url,omitempty"`
+	UserID    string         `json:"user_id,omitempty"`
+	ChannelID string         `json:"channel_id,omitempty"`
+	Name      string         `json:"name,omitempty"`
+	Range     string         `json:"range,omitempty"`
+	Elements  []BlockElement `json:"elements,omitempty"`
+}
+
+// Render returns the text of e for plain text clients: the message text, followed
+// by a compact rendering of its attachments. Blocks replace the text, which is
+// only a fallback for notifications, unless they merely repeat it as rich text.
+func (sc *Client) Render(e *Event) string {
+	var rich []string
+	text := e.Text
+	if len(e.Blocks) > 0 && (text == "" || !onlyRichText(e.Blocks)) {
+		rich = append(rich, sc.renderBlocks(e.Blocks)...)
+		text = ""
+	}
+	for _, a := range e.Attachments {
+		rich = append(rich, sc.renderAttachment(a)...)
+	}
+	if len(rich) > maxRichLines {
+		rich = append(rich[:maxRichLines-1], "…")
+	}
+	lines := rich
+	if text != "" {
+		lines = append([]string{text}, rich...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// plain converts mrkdwn to plain text, resolving links and highlights
+func (sc *Client) plain(mrkdwn string) string {
+	return strings.TrimSpace(html.UnescapeString(bracketRe.ReplaceAllStringFunc(mrkdwn, sc.unSlackify)))
+}
+
+func (sc *Client) renderAttachment(a Attachment) (lines []string) {
+	add := func(text string) {
+		for _, line := range strings.Split(text, "\n") {
+			if strings.TrimSpace(line) != "" {
+				lines = append(lines, line)
+			}
+		}
+	}
+	add(sc.plain(a.Pretext))
+	switch {
+	case a.Title != "" && a.TitleLink != "":
+		add(sc.plain(a.Title) + " (" + a.TitleLink + ")")
+	case a.Title != "":
+		add(sc.plain(a.Title))
+	case a.TitleLink != "":
+		add(a.TitleLink)
+	}
+	add(sc.plain(a.Text))
+	var fields []string
+	for _, f := range a.Fields {
+		fields = append(fields, sc.plain(f.Title)+": "+strings.Replace(sc.plain(f.Value), "\n", " ", -1))
+	}
+	add(strings.Join(fields, " | "))
+	if len(lines) == 0 {
+		add(sc.plain(a.Fallback))
+	}
+	return lines
+}
+
+func onlyRichText(blocks []Block) bool {
+	for _, b := range blocks {
+		if b.Type != "rich_text" {
+			return false
+		}
+	}
+	return true
+}
+
+func (sc *Client) renderBlocks(blocks []Block) (lines []string) {
+	add := func(text string) {
+		for _, line := range strings.Split(text, "\n") {
+			if strings.TrimSpace(line) != "" {
+				lines = append(lines, line)
+			}
+		}
+	}
+	for _, b := range blocks {
+		switch b.Type {
+		case "header":
+			if b.Text != nil {
+				add(sc.plain(b.Text.Text))
+			}
+		case "section":
+			if b.Text != nil {
+				add(sc.plain(b.Text.Text))
+			}
+			var fields []string
+			for _, f := range b.Fields {
+				fields = append(fields, strings.Replace(sc.plain(f.Text), "\n", " ", -1))
+			}
+			add(strings.Join(fields, " | "))
+		case "context":
+			var parts []string
+			for _, el := range b.Elements {
+				if el.Text != "" {
+					parts = append(parts, sc.plain(el.Text))
+				}
+			}
+			add(strings.Join(parts, " "))
+		case "rich_text":
+			for _, el := range b.Elements {
+				add(sc.renderRichText(el))
+			}
+		}
+	}
+	return lines
+}
+
+// renderRichText renders a rich_text container element
+func (sc *Client) renderRichText(el BlockElement) string {
+	switch el.Type {
+	case "rich_text_list":
+		items := make([]string, len(el.Elements))
+		for i, item := range el.Elements {
+			items[i] = "• " + sc.renderRichText(item)
+		}
+		return strings.Join(items, "\n")
+	case "rich_text_quote":
+		return "> " + strings.Replace(sc.renderLeaves(el.Elements), "\n", "\n> ", -1)
+	default:
+		// rich_text_section, rich_text_preformatted
+		return sc.renderLeaves(el.Elements)
+	}
+}
+
+func (sc *Client) renderLeaves(leaves []BlockElement) string {
+	var b strings.Builder
+	for _, leaf := range leaves {
+		switch leaf.Type {
+		case "text":
+			b.WriteString(leaf.Text)
+		case "link":
+			if leaf.Text != "" && leaf.Text != leaf.URL {
+				b.WriteString(leaf.Text + " (" + leaf.URL + ")")
+			} else {
+				b.WriteString(leaf.URL)
+			}
+		case "user":
+			b.WriteString(sc.unSlackify("<@" + leaf.UserID + ">"))
+		case "channel":
+			b.WriteString(sc.unSlackify("<#" + leaf.ChannelID + ">"))
+		case "broadcast":
+			b.WriteString("@" + leaf.Range)
+		case "emoji":
+			b.WriteString(":" + leaf.Name + ":")
+		}
+	}
+	return b.String()
+}
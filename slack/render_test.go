@@ -0,0 +1,55 @@
+package slack
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	sc := setup(t)
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{
+			"plain text",
+			`{"type":"message","text":"hello"}`,
+			"hello",
+		},
+		{
+			"rich text repeating the text",
+			`{"type":"message","text":"hello <@U11A2B8C1>","blocks":[{"type":"rich_text","elements":[{"type":"rich_text_section","elements":[{"type":"text","text":"hello "},{"type":"user","user_id":"U11A2B8C1"}]}]}]}`,
+			"hello <@U11A2B8C1>",
+		},
+		{
+			"attachment",
+			`{"type":"message","attachments":[{"fallback":"Build #42 failed","pretext":"CI","title":"Build #42 failed","title_link":"https://ci.example.org/42","text":"see <https://ci.example.org/42/log|the log>","fields":[{"title":"Branch","value":"master","short":true},{"title":"Author","value":"<@U11A2B8C1>","short":true}]}]}`,
+			"CI\nBuild #42 failed (https://ci.example.org/42)\nsee https://ci.example.org/42/log\nBranch: master | Author: @testorizor1",
+		},
+		{
+			"attachment with fallback only",
+			`{"type":"message","text":"alert","attachments":[{"fallback":"Disk full on db1"}]}`,
+			"alert\nDisk full on db1",
+		},
+		{
+			"blocks replacing the fallback text",
+			`{"type":"message","text":"New incident","blocks":[{"type":"header","text":{"type":"plain_text","text":"Incident #7"}},{"type":"section","text":{"type":"mrkdwn","text":"*db1* is down in <#C03JAPEHJ>"},"fields":[{"type":"mrkdwn","text":"Severity: high"},{"type":"mrkdwn","text":"Owner: ops"}]},{"type":"context","elements":[{"type":"mrkdwn","text":"via PagerDuty"}]}]}`,
+			"Incident #7\n*db1* is down in #dev\nSeverity: high | Owner: ops\nvia PagerDuty",
+		},
+		{
+			"rich text list and link",
+			`{"type":"message","blocks":[{"type":"rich_text","elements":[{"type":"rich_text_list","elements":[{"type":"rich_text_section","elements":[{"type":"link","url":"https://example.org","text":"docs"}]},{"type":"rich_text_section","elements":[{"type":"emoji","name":"tada"}]}]}]}]}`,
+			"• docs (https://example.org)\n• :tada:",
+		},
+	}
+	for _, tt := range tests {
+		var e Event
+		if err := json.Unmarshal([]byte(tt.raw), &e); err != nil {
+			t.Fatal(err)
+		}
+		if got := sc.Render(&e); got != tt.want {
+			t.Errorf("%s - expected: (%q) - got: (%q)", tt.name, tt.want, got)
+		}
+	}
+}
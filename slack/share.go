@@ -0,0 +1,135 @@
+package slack
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// shareWorkers is the number of files made public concurrently
+	shareWorkers = 4
+	// shareQueueSize is the number of file_public events waiting for a worker
+	shareQueueSize = 64
+	// sharedCacheTTL is the time a shared file is remembered to suppress duplicate announcements
+	sharedCacheTTL = 24 * time.Hour
+	// sharedCacheSize is the maximum number of remembered files
+	sharedCacheSize = 1024
+)
+
+// sharePipeline makes files public on a pool of workers. Every file is
+// processed at most once at a time, and not again while it is in the cache.
+type sharePipeline struct {
+	start sync.Once
+	queue chan string
+
+	mu       sync.Mutex
+	inflight map[string]bool
+	done     *ttlCache
+}
+
+func newSharePipeline() *sharePipeline {
+	return &sharePipeline{
+		queue:    make(chan string, shareQueueSize),
+		inflight: make(map[string]bool),
+		done:     newTTLCache(sharedCacheSize, sharedCacheTTL),
+	}
+}
+
+// queueShare schedules fileID to be made public without blocking the caller
+func (sc *Client) queueShare(fileID string) {
+	sp := sc.shares
+	sp.start.Do(func() {
+		for i := 0; i < shareWorkers; i++ {
+			go sc.shareWorker()
+		}
+	})
+
+	sp.mu.Lock()
+	if sp.inflight[fileID] || sp.done.has(fileID) {
+		sp.mu.Unlock()
+		return
+	}
+	sp.inflight[fileID] = true
+	sp.mu.Unlock()
+
+	select {
+	case sp.queue <- fileID:
+	default:
+		sc.logger.Warn("Too many pending file shares, dropping", "side", "slack", "file", fileID)
+		sp.finish(fileID, false)
+	}
+}
+
+func (sc *Client) shareWorker() {
+	for fileID := range sc.shares.queue {
+		err := sc.shareFile(fileID)
+		if err != nil {
+			sc.logger.Warn("Image sharing failed", "side", "slack", "file", fileID, "err", err)
+		}
+		sc.shares.finish(fileID, err == nil)
+	}
+}
+
+// finish marks fileID as no longer in flight, and remembers it if it has been shared
+func (sp *sharePipeline) finish(fileID string, shared bool) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	delete(sp.inflight, fileID)
+	if shared {
+		sp.done.add(fileID)
+	}
+}
+
+// forget allows fileID to be shared again, e.g. after its public link has been revoked
+func (sp *sharePipeline) forget(fileID string) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.done.remove(fileID)
+}
+
+// ttlCache is a set of strings whose members expire after ttl. If it is full,
+// the oldest member is evicted. It is not safe for concurrent use.
+type ttlCache struct {
+	size  int
+	ttl   time.Duration
+	order []string
+	added map[string]time.Time
+}
+
+func newTTLCache(size int, ttl time.Duration) *ttlCache {
+	return &ttlCache{size: size, ttl: ttl, added: make(map[string]time.Time)}
+}
+
+func (tc *ttlCache) add(key string) {
+	tc.remove(key)
+	tc.order = append(tc.order, key)
+	tc.added[key] = time.Now()
+	tc.expire()
+}
+
+func (tc *ttlCache) has(key string) bool {
+	tc.expire()
+	_, ok := tc.added[key]
+	return ok
+}
+
+func (tc *ttlCache) remove(key string) {
+	if _, ok := tc.added[key]; !ok {
+		return
+	}
+	delete(tc.added, key)
+	for i, k := range tc.order {
+		if k == key {
+			tc.order = append(tc.order[:i], tc.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// expire evicts expired members and the oldest members beyond size
+func (tc *ttlCache) expire() {
+	for len(tc.order) > 0 && (len(tc.order) > tc.size || time.Since(tc.added[tc.order[0]]) > tc.ttl) {
+		delete(tc.added, tc.order[0])
+		tc.order = tc.order[1:]
+	}
+}
@@ -0,0 +1,77 @@
+package slack
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSharePipeline(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first call is rate limited
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		<-release
+		fmt.Fprint(w, `{"ok":true,"file":{"id":"F1","permalink_public":"https://slack-files.com/T1-F1-abc"}}`)
+	}))
+	defer api.Close()
+
+	sc := NewClient("foobar")
+	sc.UserToken = "xoxp-test"
+	sc.APIURL = api.URL + "/"
+	published := make(chan string, 10)
+	sc.FilePublished = func(f *File) { published <- f.ID }
+
+	// duplicate events while the share is in flight
+	for i := 0; i < 5; i++ {
+		sc.queueShare("F1")
+	}
+	close(release)
+	select {
+	case id := <-published:
+		if id != "F1" {
+			t.Errorf("expected F1 to be published, got %v", id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("F1 has not been published")
+	}
+
+	// and after it has been shared
+	sc.queueShare("F1")
+	select {
+	case id := <-published:
+		t.Errorf("%v has been published twice", id)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("expected 2 API calls (429 and retry), got %v", n)
+	}
+}
+
+func TestTTLCache(t *testing.T) {
+	tc := newTTLCache(2, time.Hour)
+	tc.add("a")
+	tc.add("b")
+	tc.add("c")
+	if tc.has("a") || !tc.has("b") || !tc.has("c") {
+		t.Errorf("expected the oldest entry to be evicted: %v", tc.order)
+	}
+	tc.remove("b")
+	if tc.has("b") {
+		t.Error("removed entry is still cached")
+	}
+
+	tc = newTTLCache(10, -time.Second)
+	tc.add("a")
+	if tc.has("a") {
+		t.Error("expired entry is still cached")
+	}
+}
@@ -0,0 +1,190 @@
+package slack
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// TypedEvent is an RTM event decoded into the struct for its type, see DecodeEvent
+type TypedEvent interface {
+	meta() *EventMeta
+}
+
+// EventMeta is embedded in every typed event
+type EventMeta struct {
+	Type    string `json:"type"`
+	EventTs string `json:"event_ts,omitempty"`
+	// Raw is the payload as received from Slack, including fields the struct does not know
+	Raw json.RawMessage `json:"-"`
+}
+
+func (m *EventMeta) meta() *EventMeta { return m }
+
+// MessageEvent is a message, including edits (SubType "message_changed") and deletions
+// ("message_deleted"). Text is in Slack markup, e.g. "<@U123>".
+// See https://api.slack.com/events/message
+type MessageEvent struct {
+	EventMeta
+	SubType   string `json:"subtype,omitempty"`
+	ChannelID string `json:"channel"`
+	UserID    string `json:"user,omitempty"`
+	BotID     string `json:"bot_id,omitempty"`
+	BotName   string `json:"username,omitempty"`
+	Text      string `json:"text,omitempty"`
+	Ts        string `json:"ts,omitempty"`
+	ThreadTs  string `json:"thread_ts,omitempty"`
+	Edited    *struct {
+		UserID string `json:"user"`
+		Ts     string `json:"ts"`
+	} `json:"edited,omitempty"`
+	Hidden    bool   `json:"hidden,omitempty"`
+	DeletedTs string `json:"deleted_ts,omitempty"`
+	// Message is the edited message of a message_changed event,
+	// PreviousMessage the message before the change or deletion
+	Message         *MessageEvent `json:"message,omitempty"`
+	PreviousMessage *MessageEvent `json:"previous_message,omitempty"`
+
+	Files       []File       `json:"files,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+	Blocks      []Block      `json:"blocks,omitempty"`
+}
+
+// ReactionEvent is a reaction_added or reaction_removed event
+type ReactionEvent struct {
+	EventMeta
+	UserID   string `json:"user"`
+	Reaction string `json:"reaction"`
+	ItemUser string `json:"item_user,omitempty"`
+	Item     struct {
+		Type      string `json:"type"` // message, file or file_comment
+		ChannelID string `json:"channel,omitempty"`
+		Ts        string `json:"ts,omitempty"`
+		FileID    string `json:"file,omitempty"`
+	} `json:"item"`
+}
+
+// PresenceEvent is a presence_change or manual_presence_change event
+type PresenceEvent struct {
+	EventMeta
+	UserID   string   `json:"user,omitempty"`
+	Users    []string `json:"users,omitempty"` // batched presence_change
+	Presence string   `json:"presence"`        // active, away
+}
+
+// FileSharedEvent is a file_shared or file_public event
+type FileSharedEvent struct {
+	EventMeta
+	FileID    string `json:"file_id"`
+	UserID    string `json:"user_id,omitempty"`
+	ChannelID string `json:"channel_id,omitempty"`
+}
+
+// eventDecoders returns the typed event for each known event type
+var eventDecoders = map[string]func() TypedEvent{
+	"message":                func() TypedEvent { return new(MessageEvent) },
+	"reaction_added":         func() TypedEvent { return new(ReactionEvent) },
+	"reaction_removed":       func() TypedEvent { return new(ReactionEvent) },
+	"presence_change":        func() TypedEvent { return new(PresenceEvent) },
+	"manual_presence_change": func() TypedEvent { return new(PresenceEvent) },
+	"file_shared":            func() TypedEvent { return new(FileSharedEvent) },
+	"file_public":            func() TypedEvent { return new(FileSharedEvent) },
+}
+
+func init() {
+	for eventType := range channelEvents {
+		eventDecoders[eventType] = func() TypedEvent { return new(ChannelEvent) }
+	}
+}
+
+// DecodeEvent decodes the RTM event raw into its typed event. It returns nil
+// without error for event types that have no typed event.
+func DecodeEvent(raw json.RawMessage) (TypedEvent, error) {
+	var et EventType
+	if err := json.Unmarshal(raw, &et); err != nil {
+		return nil, err
+	}
+	decoder, ok := eventDecoders[et.Type]
+	if !ok {
+		return nil, nil
+	}
+	te := decoder()
+	if err := json.Unmarshal(raw, te); err != nil {
+		return nil, fmt.Errorf("Failed to decode %s: %v", et.Type, err)
+	}
+	te.meta().Raw = raw
+	return te, nil
+}
+
+// Typed returns the typed event of se, decoded from Raw. Events that have not been
+// received from Slack, e.g. file share announcements, are decoded from se itself.
+func (se *Event) Typed() (TypedEvent, error) {
+	raw := se.Raw
+	if raw == nil {
+		var err error
+		if raw, err = json.Marshal(se); err != nil {
+			return nil, err
+		}
+	}
+	return DecodeEvent(raw)
+}
+
+// onTyped registers h for all events that have a typed event
+func (sc *Client) onTyped(h func(*Client, TypedEvent)) *Registration {
+	return sc.HandleFunc(AllEvents, func(sc *Client, e *Event) {
+		te, err := e.Typed()
+		if err != nil {
+			sc.logger.Warn("Failed to decode event", "side", "slack", "type", e.Type, "err", err)
+			return
+		}
+		if te != nil {
+			h(sc, te)
+		}
+	})
+}
+
+// OnMessage registers h for message events, including messages to the bot that
+// are dispatched as "command" or "admincommand" to HandleFunc handlers
+func (sc *Client) OnMessage(h func(*Client, *MessageEvent)) *Registration {
+	return sc.onTyped(func(sc *Client, te TypedEvent) {
+		if me, ok := te.(*MessageEvent); ok {
+			h(sc, me)
+		}
+	})
+}
+
+// OnReaction registers h for reaction_added and reaction_removed events
+func (sc *Client) OnReaction(h func(*Client, *ReactionEvent)) *Registration {
+	return sc.onTyped(func(sc *Client, te TypedEvent) {
+		if re, ok := te.(*ReactionEvent); ok {
+			h(sc, re)
+		}
+	})
+}
+
+// OnPresence registers h for presence changes
+func (sc *Client) OnPresence(h func(*Client, *PresenceEvent)) *Registration {
+	return sc.onTyped(func(sc *Client, te TypedEvent) {
+		if pe, ok := te.(*PresenceEvent); ok {
+			h(sc, pe)
+		}
+	})
+}
+
+// OnChannel registers h for the events that change the channel directory.
+// The directory has already been updated when h is called.
+func (sc *Client) OnChannel(h func(*Client, *ChannelEvent)) *Registration {
+	return sc.onTyped(func(sc *Client, te TypedEvent) {
+		if ce, ok := te.(*ChannelEvent); ok {
+			h(sc, ce)
+		}
+	})
+}
+
+// OnFileShared registers h for file_shared and file_public events
+func (sc *Client) OnFileShared(h func(*Client, *FileSharedEvent)) *Registration {
+	return sc.onTyped(func(sc *Client, te TypedEvent) {
+		if fe, ok := te.(*FileSharedEvent); ok {
+			h(sc, fe)
+		}
+	})
+}
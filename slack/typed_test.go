@@ -0,0 +1,86 @@
+package slack
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecodeEvent(t *testing.T) {
+	edit := `{"type":"message","subtype":"message_changed","hidden":true,"channel":"C11JBA78E","ts":"1358878755.000001",` +
+		`"message":{"type":"message","user":"U11A2B8C1","text":"Hello, world!","ts":"1358878749.000002","thread_ts":"1358878700.000001","edited":{"user":"U11A2B8C1","ts":"1358878755.000001"}},` +
+		`"previous_message":{"type":"message","user":"U11A2B8C1","text":"Helo, world!","ts":"1358878749.000002"},"unknown_field":42}`
+	te, err := DecodeEvent(json.RawMessage(edit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	me, ok := te.(*MessageEvent)
+	if !ok {
+		t.Fatalf("message_changed - expected *MessageEvent - got: %T", te)
+	}
+	if me.SubType != "message_changed" || !me.Hidden || me.Message == nil || me.Message.Text != "Hello, world!" ||
+		me.Message.Edited == nil || me.Message.Edited.Ts != me.Ts || me.Message.ThreadTs != "1358878700.000001" ||
+		me.PreviousMessage == nil || me.PreviousMessage.Text != "Helo, world!" {
+		t.Errorf("message_changed decoded incorrectly: %+v", me)
+	}
+	if string(me.Raw) != edit {
+		t.Errorf("Raw - expected: %s - got: %s", edit, me.Raw)
+	}
+
+	tests := []struct {
+		raw   string
+		check func(TypedEvent) bool
+	}{
+		{`{"type":"reaction_added","user":"U1","reaction":"thumbsup","item_user":"U2","item":{"type":"message","channel":"C1","ts":"1360782400.498405"},"event_ts":"1360782804.083113"}`,
+			func(te TypedEvent) bool {
+				re, ok := te.(*ReactionEvent)
+				return ok && re.Reaction == "thumbsup" && re.Item.ChannelID == "C1" && re.Item.Ts == "1360782400.498405" && re.EventTs == "1360782804.083113"
+			}},
+		{`{"type":"presence_change","users":["U1","U2"],"presence":"away"}`,
+			func(te TypedEvent) bool {
+				pe, ok := te.(*PresenceEvent)
+				return ok && len(pe.Users) == 2 && pe.Presence == "away"
+			}},
+		{`{"type":"file_shared","file_id":"F1","user_id":"U1","channel_id":"C1"}`,
+			func(te TypedEvent) bool {
+				fe, ok := te.(*FileSharedEvent)
+				return ok && fe.FileID == "F1" && fe.ChannelID == "C1"
+			}},
+		{`{"type":"channel_rename","channel":{"id":"C1","name":"renamed"}}`,
+			func(te TypedEvent) bool {
+				ce, ok := te.(*ChannelEvent)
+				return ok && ce.Type == "channel_rename" && ce.ChannelID() == "C1"
+			}},
+		{`{"type":"user_typing","channel":"C1","user":"U1"}`,
+			func(te TypedEvent) bool { return te == nil }},
+	}
+	for _, tt := range tests {
+		te, err := DecodeEvent(json.RawMessage(tt.raw))
+		if err != nil || !tt.check(te) {
+			t.Errorf("%s - got: (%#v, %v)", tt.raw, te, err)
+		}
+	}
+}
+
+func TestOnMessage(t *testing.T) {
+	sc := NewClient("foobar")
+	got := make(chan *MessageEvent, 2)
+	reg := sc.OnMessage(func(sc *Client, me *MessageEvent) { got <- me })
+	sc.OnReaction(func(sc *Client, re *ReactionEvent) { t.Errorf("OnReaction called for %s", re.Type) })
+
+	// messages to the bot are dispatched as commands, but are still messages
+	raw := `{"type":"message","channel":"C1","user":"U1","text":"<@U0> help"}`
+	sc.disPatchHandlers(&Event{Type: "command", ChannelID: "C1", Text: "help", Raw: json.RawMessage(raw)})
+	if me := <-got; me.Text != "<@U0> help" || string(me.Raw) != raw {
+		t.Errorf("command - got: %+v", me)
+	}
+	// events without payload, e.g. file share announcements
+	sc.disPatchHandlers(&Event{Type: "message", ChannelID: "C1", Text: "has shared a file"})
+	if me := <-got; me.Text != "has shared a file" || me.ChannelID != "C1" {
+		t.Errorf("event without Raw - got: %+v", me)
+	}
+
+	reg.Remove()
+	if n := len(sc.handlersFor("message")); n != 1 {
+		t.Errorf("after Remove - expected 1 handler - got: %d", n)
+	}
+}
@@ -11,7 +11,7 @@ import (
 var bracketRe = regexp.MustCompile("(<.+?>)")
 
 func (sc *Client) nickForUserID(userID string) string {
-	user, ok := sc.userIDMap[userID]
+	user, ok := sc.dir.user(userID)
 	if ok {
 		if user.Profile.DisplayName == "" {
 			return user.Name
@@ -21,6 +21,20 @@ func (sc *Client) nickForUserID(userID string) string {
 	return userID
 }
 
+// UserName returns the display name of the user with userID, or userID if the user is unknown
+func (sc *Client) UserName(userID string) string {
+	return sc.nickForUserID(userID)
+}
+
+// ChannelName returns the name of the channel with channelID, or channelID if the channel is unknown
+func (sc *Client) ChannelName(channelID string) string {
+	channel, ok := sc.dir.channel(channelID)
+	if ok {
+		return channel.Name
+	}
+	return channelID
+}
+
 func (sc *Client) unSlackify(str string) string {
 	// Links e.g. <http://heise.de|heise.de>, <http://heise.de>
 	if strings.HasPrefix(str, "<http") {
@@ -33,7 +47,12 @@ func (sc *Client) unSlackify(str string) string {
 	// Highlights e. g. <@U02A2A2A2>
 	if strings.HasPrefix(str, "<@U") {
 		userID := str[2 : len(str)-1]
-		user, ok := sc.userIDMap[userID]
+		if sc.UserMention != nil {
+			if mention := sc.UserMention(userID); mention != "" {
+				return mention
+			}
+		}
+		user, ok := sc.dir.user(userID)
 		if ok {
 			if user.Profile.DisplayName != "" {
 				return fmt.Sprint("@", user.Profile.DisplayName)
@@ -56,7 +75,7 @@ func (sc *Client) unSlackify(str string) string {
 	// Channels <#C02A2A2A2>
 	if strings.HasPrefix(str, "<#C") {
 		chanID := str[2 : len(str)-1]
-		channel, ok := sc.chanIDMap[chanID]
+		channel, ok := sc.dir.channel(chanID)
 		if ok {
 			return fmt.Sprintf("#%v", channel.Name)
 		}
@@ -55,3 +55,22 @@ func TestUnslackify(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestUserMention(t *testing.T) {
+	sc := setup(t)
+	sc.UserMention = func(userID string) string {
+		if userID == "U11A2B8C1" {
+			return "jdoe"
+		}
+		return ""
+	}
+	raw := "<@U11A2B8C1> and <@U11A2BBCK>"
+	want := "jdoe and @testorizor2"
+
+	got := bracketRe.ReplaceAllStringFunc(raw, sc.unSlackify)
+	if got != want {
+		t.Logf("Got: %v", got)
+		t.Logf("Want: %v", want)
+		t.Fail()
+	}
+}
@@ -0,0 +1,422 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// apiRetries is the number of attempts made for a Web API call
+	apiRetries = 3
+	// maxRetryAfter caps the time we wait if slack asks us to back off
+	maxRetryAfter = time.Minute
+)
+
+// APIError is an error returned by a Web API method
+type APIError struct {
+	Method string
+	Code   string // e.g. "channel_not_found"
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s failed: %s", e.Method, e.Code)
+}
+
+// IsAPIError reports whether err is an *APIError with code
+func IsAPIError(err error, code string) bool {
+	apiErr, ok := err.(*APIError)
+	return ok && apiErr.Code == code
+}
+
+// RateLimitedError is returned if slack still rate limits a call after all retries
+type RateLimitedError struct {
+	Method     string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("%s rate limited, retry after %v", e.Method, e.RetryAfter)
+}
+
+type httpStatusError struct {
+	status string
+}
+
+func (e *httpStatusError) Error() string {
+	return "unexpected HTTP status: " + e.status
+}
+
+// tierLimits are the calls per minute of the Web API rate limit tiers, see https://api.slack.com/docs/rate-limits
+var tierLimits = map[int]int{1: 1, 2: 20, 3: 50, 4: 100}
+
+// methodTiers are the rate limit tiers of the methods we use, others are treated as tier 3.
+// chat.postMessage is limited to about one message per second.
+var methodTiers = map[string]int{
+	"conversations.setTopic": 2,
+	"users.info":             4,
+	"files.info":             4,
+}
+
+// nonIdempotent are the methods that must not be sent twice: a retried call may post
+// or react again if the first attempt reached slack. They are only retried after a 429
+// or if the connection to slack could not be established.
+var nonIdempotent = map[string]bool{
+	"chat.postMessage":       true,
+	"chat.update":            true,
+	"conversations.setTopic": true,
+	"reactions.add":          true,
+}
+
+func methodLimit(method string) int {
+	if method == "chat.postMessage" {
+		return 60
+	}
+	if tier, ok := methodTiers[method]; ok {
+		return tierLimits[tier]
+	}
+	return tierLimits[3]
+}
+
+// rateLimiter is a token bucket that allows limit calls per minute, in bursts of up to limit calls
+type rateLimiter struct {
+	mu     sync.Mutex
+	limit  float64
+	tokens float64
+	last   time.Time
+	until  time.Time // slack asked us to back off until then
+}
+
+func newRateLimiter(limit int) *rateLimiter {
+	return &rateLimiter{limit: float64(limit), tokens: float64(limit), last: time.Now()}
+}
+
+// reserve takes a token and returns how long the caller has to wait before making the call
+func (rl *rateLimiter) reserve() time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	now := time.Now()
+	rl.tokens += now.Sub(rl.last).Minutes() * rl.limit
+	if rl.tokens > rl.limit {
+		rl.tokens = rl.limit
+	}
+	rl.last = now
+	rl.tokens--
+	var delay time.Duration
+	if rl.tokens < 0 {
+		delay = time.Duration(-rl.tokens / rl.limit * float64(time.Minute))
+	}
+	if backoff := rl.until.Sub(now); backoff > delay {
+		delay = backoff
+	}
+	return delay
+}
+
+// unreserve gives back the token of a call that is not made
+func (rl *rateLimiter) unreserve() {
+	rl.mu.Lock()
+	rl.tokens++
+	rl.mu.Unlock()
+}
+
+// wait blocks until the next call may be made, or ctx is done
+func (rl *rateLimiter) wait(ctx context.Context) error {
+	if err := sleep(ctx, rl.reserve()); err != nil {
+		rl.unreserve()
+		return err
+	}
+	return nil
+}
+
+// backOff makes all callers wait for d, e.g. after a 429
+func (rl *rateLimiter) backOff(d time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if until := time.Now().Add(d); until.After(rl.until) {
+		rl.until = until
+	}
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// limiter returns the rate limiter of method
+func (sc *Client) limiter(method string) *rateLimiter {
+	sc.limitmu.Lock()
+	defer sc.limitmu.Unlock()
+	if sc.limiters == nil {
+		sc.limiters = make(map[string]*rateLimiter)
+	}
+	rl, ok := sc.limiters[method]
+	if !ok {
+		rl = newRateLimiter(methodLimit(method))
+		sc.limiters[method] = rl
+	}
+	return rl
+}
+
+// call invokes the Web API method with params, authenticated by the bot token,
+// and decodes the response into v unless v is nil
+func (sc *Client) call(ctx context.Context, method string, params url.Values, v interface{}) error {
+	return sc.callWithToken(ctx, sc.BotToken, method, params, v)
+}
+
+// callWithToken is call authenticated by token. Failed calls return an *APIError
+// or, if slack kept rate limiting the call, a *RateLimitedError.
+func (sc *Client) callWithToken(ctx context.Context, token, method string, params url.Values, v interface{}) error {
+	body := params.Encode()
+	resp, err := sc.doWithRetry(ctx, method, func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", sc.APIURL+method, strings.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create %s request: %v", method, err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "Bearer "+token)
+		return req.WithContext(ctx), nil
+	})
+	if err != nil {
+		if _, ok := err.(*RateLimitedError); ok || err == ctx.Err() {
+			return err
+		}
+		return fmt.Errorf("%s request failed: %v", method, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("Failed to read %s API response: %v", method, err)
+	}
+	var status struct {
+		Ok    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(data, &status); err != nil {
+		return fmt.Errorf("Failed to unmarshal %s API response: %v", method, err)
+	}
+	if !status.Ok {
+		return &APIError{Method: method, Code: status.Error}
+	}
+	if v != nil {
+		if err := json.Unmarshal(data, v); err != nil {
+			return fmt.Errorf("Failed to unmarshal %s API response: %v", method, err)
+		}
+	}
+	return nil
+}
+
+// doWithRetry sends the request returned by newReq, retrying on network errors, 429 and 5xx;
+// non-idempotent methods are only retried on 429 and dial errors.
+// Every attempt takes a token of the rate limiter of method. If slack answers 429,
+// the Retry-After header is honoured for all calls of method.
+func (sc *Client) doWithRetry(ctx context.Context, method string, newReq func() (*http.Request, error)) (resp *http.Response, err error) {
+	rl := sc.limiter(method)
+	for attempt := 1; ; attempt++ {
+		if err := rl.wait(ctx); err != nil {
+			return nil, err
+		}
+		req, err := newReq()
+		if err != nil {
+			return nil, err
+		}
+		resp, err = sc.httpClient.Do(req)
+		wait := time.Duration(attempt) * time.Second
+		rateLimited := false
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if nonIdempotent[method] && !isDialError(err) {
+				return nil, err
+			}
+		case resp.StatusCode == http.StatusTooManyRequests:
+			wait = retryAfter(resp)
+			rateLimited = true
+			resp.Body.Close()
+			rl.backOff(wait)
+		case resp.StatusCode >= 500:
+			resp.Body.Close()
+			if nonIdempotent[method] {
+				return nil, &httpStatusError{resp.Status}
+			}
+		default:
+			return resp, nil
+		}
+		if attempt == apiRetries {
+			switch {
+			case rateLimited:
+				return nil, &RateLimitedError{Method: method, RetryAfter: wait}
+			case err == nil:
+				return nil, &httpStatusError{resp.Status}
+			}
+			return nil, err
+		}
+		if rateLimited {
+			// the rate limiter waits for Retry-After
+			continue
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// isDialError reports whether err happened before the request was sent,
+// while connecting to slack or to the proxy
+func isDialError(err error) bool {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	opErr, ok := err.(*net.OpError)
+	return ok && (opErr.Op == "dial" || opErr.Op == "proxyconnect")
+}
+
+// retryAfter returns the delay requested by a 429 response, see https://api.slack.com/docs/rate-limits
+func retryAfter(resp *http.Response) time.Duration {
+	secs, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || secs < 0 {
+		return time.Second
+	}
+	if wait := time.Duration(secs) * time.Second; wait < maxRetryAfter {
+		return wait
+	}
+	return maxRetryAfter
+}
+
+// PostMessageParams are the arguments of chat.postMessage
+type PostMessageParams struct {
+	ChannelID string
+	Text      string
+	ThreadTs  string // reply in this thread
+	Username  string // post under this name, requires the chat:write.customize scope
+	IconURL   string
+	Relayed   bool // mark the message as relayed from another network, see Client.Relay
+}
+
+// PostMessage posts a message and returns its ts
+// See https://api.slack.com/methods/chat.postMessage
+func (sc *Client) PostMessage(ctx context.Context, p PostMessageParams) (ts string, err error) {
+	text := p.Text
+	if p.Relayed {
+		text = markBridged(text)
+	}
+	params := url.Values{"channel": {p.ChannelID}, "text": {text}}
+	for key, value := range map[string]string{"thread_ts": p.ThreadTs, "username": p.Username, "icon_url": p.IconURL} {
+		if value != "" {
+			params.Set(key, value)
+		}
+	}
+	var resp struct {
+		Ts string `json:"ts"`
+	}
+	err = sc.call(ctx, "chat.postMessage", params, &resp)
+	return resp.Ts, err
+}
+
+// UpdateMessage replaces the text of the bot's message ts
+// See https://api.slack.com/methods/chat.update
+func (sc *Client) UpdateMessage(ctx context.Context, channelID, ts, text string) error {
+	return sc.call(ctx, "chat.update", url.Values{"channel": {channelID}, "ts": {ts}, "text": {text}}, nil)
+}
+
+// DeleteMessage deletes the bot's message ts
+// See https://api.slack.com/methods/chat.delete
+func (sc *Client) DeleteMessage(ctx context.Context, channelID, ts string) error {
+	return sc.call(ctx, "chat.delete", url.Values{"channel": {channelID}, "ts": {ts}}, nil)
+}
+
+// ConversationInfo fetches the channel with channelID
+// See https://api.slack.com/methods/conversations.info
+func (sc *Client) ConversationInfo(ctx context.Context, channelID string) (*Channel, error) {
+	var resp struct {
+		Channel Channel `json:"channel"`
+	}
+	if err := sc.call(ctx, "conversations.info", url.Values{"channel": {channelID}}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Channel, nil
+}
+
+// ConversationHistory returns up to limit of the latest messages of channelID, newest first
+// See https://api.slack.com/methods/conversations.history
+func (sc *Client) ConversationHistory(ctx context.Context, channelID string, limit int) ([]MessageEvent, error) {
+	var resp struct {
+		Messages []MessageEvent `json:"messages"`
+	}
+	params := url.Values{"channel": {channelID}, "limit": {strconv.Itoa(limit)}}
+	if err := sc.call(ctx, "conversations.history", params, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Messages, nil
+}
+
+// SetTopic sets the topic of channelID
+// See https://api.slack.com/methods/conversations.setTopic
+func (sc *Client) SetTopic(ctx context.Context, channelID, topic string) error {
+	return sc.call(ctx, "conversations.setTopic", url.Values{"channel": {channelID}, "topic": {topic}}, nil)
+}
+
+// UserInfo fetches the user with userID
+// See https://api.slack.com/methods/users.info
+func (sc *Client) UserInfo(ctx context.Context, userID string) (*User, error) {
+	var resp struct {
+		User User `json:"user"`
+	}
+	if err := sc.call(ctx, "users.info", url.Values{"user": {userID}}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.User, nil
+}
+
+// AddReaction adds the emoji name (without colons) to the message ts
+// See https://api.slack.com/methods/reactions.add
+func (sc *Client) AddReaction(ctx context.Context, channelID, ts, name string) error {
+	return sc.call(ctx, "reactions.add", url.Values{"channel": {channelID}, "timestamp": {ts}, "name": {name}}, nil)
+}
+
+// FileInfo returns the metadata of the file with fileID
+// See https://api.slack.com/methods/files.info
+func (sc *Client) FileInfo(ctx context.Context, fileID string) (*File, error) {
+	var resp FileApiResp
+	if err := sc.call(ctx, "files.info", url.Values{"file": {fileID}}, &resp); err != nil {
+		return nil, err
+	}
+	if resp.File == nil {
+		return nil, &APIError{Method: "files.info", Code: "file_not_found"}
+	}
+	return resp.File, nil
+}
+
+// openConversation returns the ID of the IM channel with userID
+// See https://api.slack.com/methods/conversations.open
+func (sc *Client) openConversation(ctx context.Context, userID string) (string, error) {
+	var resp struct {
+		Channel struct {
+			ID string `json:"id"`
+		} `json:"channel"`
+	}
+	if err := sc.call(ctx, "conversations.open", url.Values{"users": {userID}}, &resp); err != nil {
+		return "", err
+	}
+	return resp.Channel.ID, nil
+}
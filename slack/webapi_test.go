@@ -0,0 +1,181 @@
+package slack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebAPI(t *testing.T) {
+	var topicCalls int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer foobar" || r.FormValue("token") != "" {
+			fmt.Fprint(w, `{"ok":false,"error":"not_authed"}`)
+			return
+		}
+		switch r.URL.Path {
+		case "/chat.postMessage":
+			if r.FormValue("channel") != "C1" {
+				fmt.Fprint(w, `{"ok":false,"error":"channel_not_found"}`)
+				return
+			}
+			if r.FormValue("text") != "hello"+bridgeMarker || r.FormValue("thread_ts") != "1.0" {
+				fmt.Fprint(w, `{"ok":false,"error":"invalid_arguments"}`)
+				return
+			}
+			fmt.Fprint(w, `{"ok":true,"channel":"C1","ts":"1503435956.000247"}`)
+		case "/conversations.history":
+			fmt.Fprint(w, `{"ok":true,"messages":[{"type":"message","user":"U1","text":"newer","ts":"2.0","reply_count":3},{"type":"message","user":"U2","text":"older","ts":"1.0"}],"has_more":true}`)
+		case "/users.info":
+			fmt.Fprintf(w, `{"ok":true,"user":{"id":%q,"name":"spengler","is_admin":true}}`, r.FormValue("user"))
+		case "/reactions.add":
+			if r.FormValue("timestamp") != "1.0" || r.FormValue("name") != "thumbsup" {
+				fmt.Fprint(w, `{"ok":false,"error":"invalid_arguments"}`)
+				return
+			}
+			fmt.Fprint(w, `{"ok":true}`)
+		case "/conversations.setTopic":
+			// rate limited once, then accepted
+			if atomic.AddInt32(&topicCalls, 1) == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			fmt.Fprint(w, `{"ok":true,"channel":{"id":"C1"}}`)
+		case "/chat.delete":
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			fmt.Fprint(w, `{"ok":false,"error":"unknown_method"}`)
+		}
+	}))
+	defer api.Close()
+
+	sc := NewClient("foobar")
+	sc.APIURL = api.URL + "/"
+	ctx := context.Background()
+
+	ts, err := sc.PostMessage(ctx, PostMessageParams{ChannelID: "C1", Text: "hello", ThreadTs: "1.0", Relayed: true})
+	if err != nil || ts != "1503435956.000247" {
+		t.Errorf("PostMessage - got: (%v, %v)", ts, err)
+	}
+	_, err = sc.PostMessage(ctx, PostMessageParams{ChannelID: "C2", Text: "hello"})
+	if !IsAPIError(err, "channel_not_found") || err.Error() != "chat.postMessage failed: channel_not_found" {
+		t.Errorf("PostMessage to an unknown channel - got: %v", err)
+	}
+
+	msgs, err := sc.ConversationHistory(ctx, "C1", 2)
+	if err != nil || len(msgs) != 2 || msgs[0].Text != "newer" || msgs[1].UserID != "U2" {
+		t.Errorf("ConversationHistory - got: (%+v, %v)", msgs, err)
+	}
+	user, err := sc.UserInfo(ctx, "U1")
+	if err != nil || user.ID != "U1" || user.Name != "spengler" || !user.IsAdmin {
+		t.Errorf("UserInfo - got: (%+v, %v)", user, err)
+	}
+	if err := sc.AddReaction(ctx, "C1", "1.0", "thumbsup"); err != nil {
+		t.Errorf("AddReaction - got: %v", err)
+	}
+	if err := sc.SetTopic(ctx, "C1", "bridged to #slirc"); err != nil || atomic.LoadInt32(&topicCalls) != 2 {
+		t.Errorf("SetTopic after a 429 - got: (%v) after %d calls", err, topicCalls)
+	}
+	err = sc.DeleteMessage(ctx, "C1", "1.0")
+	if rle, ok := err.(*RateLimitedError); !ok || rle.Method != "chat.delete" {
+		t.Errorf("DeleteMessage rate limited - expected *RateLimitedError - got: %v", err)
+	}
+	// every attempt has taken a token
+	rl := sc.limiter("chat.delete")
+	rl.mu.Lock()
+	used := float64(methodLimit("chat.delete")) - rl.tokens
+	rl.mu.Unlock()
+	if used < apiRetries-0.5 {
+		t.Errorf("DeleteMessage - expected %d attempts to be rate limited - got: %.1f", apiRetries, used)
+	}
+	if err := sc.UpdateMessage(ctx, "C1", "1.0", "edited"); !IsAPIError(err, "unknown_method") {
+		t.Errorf("UpdateMessage - got: %v", err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := sc.ConversationInfo(canceled, "C1"); err != context.Canceled {
+		t.Errorf("canceled context - expected context.Canceled - got: %v", err)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	rl := newRateLimiter(60)
+	ctx := context.Background()
+	start := time.Now()
+	for i := 0; i < 60; i++ {
+		if err := rl.wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("burst has been delayed by %v", elapsed)
+	}
+
+	// the bucket is empty, the next call is due in a second
+	short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := rl.wait(short); err != context.DeadlineExceeded {
+		t.Errorf("expected the call to be delayed - got: %v", err)
+	}
+
+	// the canceled call has given its token back
+	rl.mu.Lock()
+	tokens := rl.tokens
+	rl.mu.Unlock()
+	if tokens < -0.5 {
+		t.Errorf("canceled call kept its token - tokens: %v", tokens)
+	}
+
+	rl = newRateLimiter(100)
+	rl.backOff(time.Minute)
+	short, cancel = context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := rl.wait(short); err != context.DeadlineExceeded {
+		t.Errorf("expected the call to wait for the back off - got: %v", err)
+	}
+}
+
+func TestRetryNonIdempotent(t *testing.T) {
+	var calls int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		http.Error(w, "upstream timeout", http.StatusGatewayTimeout)
+	}))
+	defer api.Close()
+
+	sc := NewClient("foobar")
+	sc.APIURL = api.URL + "/"
+	ctx := context.Background()
+
+	// the message may have been posted, it must not be sent again
+	if _, err := sc.PostMessage(ctx, PostMessageParams{ChannelID: "C1", Text: "hello"}); err == nil {
+		t.Error("PostMessage - expected an error")
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("PostMessage after a 504 - expected 1 call - got: %d", n)
+	}
+	if err := sc.SetTopic(ctx, "C1", "bridged to #slirc"); err == nil {
+		t.Error("SetTopic - expected an error")
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("SetTopic after a 504 - expected 1 call - got: %d", n-1)
+	}
+
+	// nothing has been sent if the connection could not be established
+	if !isDialError(&url.Error{Op: "Post", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}) {
+		t.Error("dial error not recognized")
+	}
+	if isDialError(&url.Error{Op: "Post", Err: &net.OpError{Op: "read", Err: errors.New("connection reset by peer")}}) {
+		t.Error("read error taken for a dial error")
+	}
+}
@@ -0,0 +1,34 @@
+package slirc
+
+import (
+	"testing"
+
+	ircc "github.com/fluffle/goirc/client"
+
+	"github.com/simonkern/slirc/slack"
+)
+
+func TestFollowRename(t *testing.T) {
+	bridge := &Bridge{
+		SlackChan: "slirctest",
+		IRCChan:   "#slirctest",
+		irc:       ircc.Client(ircc.NewConfig("slirc")),
+		log:       slack.NewStdLogger(nil, false),
+	}
+	bridge.slackChanName = bridge.SlackChan
+	bridge.slackChanID = "C1"
+
+	bridge.followRename(nil, &slack.Event{Type: "channel_rename", ChannelID: "C2", Channelname: "other-renamed", PrevChannelname: "other"})
+	if got := bridge.slackChan(); got != "slirctest" {
+		t.Errorf("rename of another channel - expected: (slirctest) - got: (%v)", got)
+	}
+	// another channel that once had the bridged channel's name
+	bridge.followRename(nil, &slack.Event{Type: "channel_rename", ChannelID: "C2", Channelname: "slirctest-old", PrevChannelname: "slirctest"})
+	if got := bridge.slackChan(); got != "slirctest" {
+		t.Errorf("rename of another channel by name - expected: (slirctest) - got: (%v)", got)
+	}
+	bridge.followRename(nil, &slack.Event{Type: "channel_rename", ChannelID: "C1", Channelname: "slirc-renamed", PrevChannelname: "slirctest"})
+	if got := bridge.slackChan(); got != "slirc-renamed" {
+		t.Errorf("rename of the bridged channel - expected: (slirc-renamed) - got: (%v)", got)
+	}
+}
@@ -0,0 +1,94 @@
+package slirc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ircTLSConfig returns the tls configuration for irc connections
+func (c *Config) ircTLSConfig() (*tls.Config, error) {
+	tlsCfg := &tls.Config{ServerName: c.IRCServer}
+	if c.IRCTLSServerName != "" {
+		tlsCfg.ServerName = c.IRCTLSServerName
+	} else if host, _, err := net.SplitHostPort(c.IRCServer); err == nil {
+		tlsCfg.ServerName = host
+	}
+
+	if c.IRCTLSMinVersion != "" {
+		version, ok := tlsVersions[c.IRCTLSMinVersion]
+		if !ok {
+			return nil, fmt.Errorf("IRCTLSMinVersion: unknown version %q, use 1.0, 1.1, 1.2 or 1.3", c.IRCTLSMinVersion)
+		}
+		tlsCfg.MinVersion = version
+	}
+
+	if c.IRCCAFile != "" {
+		pem, err := ioutil.ReadFile(c.IRCCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("IRCCAFile: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("IRCCAFile: no PEM encoded certificates found in %s", c.IRCCAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if c.IRCClientCert != "" || c.IRCClientKey != "" {
+		if c.IRCClientCert == "" || c.IRCClientKey == "" {
+			return nil, errors.New("IRCClientCert and IRCClientKey have to be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(c.IRCClientCert, c.IRCClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("IRCClientCert: %v", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if c.IRCServerFingerprint != "" {
+		pin, err := parseFingerprint(c.IRCServerFingerprint)
+		if err != nil {
+			return nil, fmt.Errorf("IRCServerFingerprint: %v", err)
+		}
+		// Without a CA the pin replaces chain verification, e.g. for self-signed certificates
+		tlsCfg.InsecureSkipVerify = c.IRCCAFile == ""
+		tlsCfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("server presented no certificate")
+			}
+			sum := sha256.Sum256(rawCerts[0])
+			if !bytes.Equal(sum[:], pin) {
+				return fmt.Errorf("server certificate fingerprint %s does not match IRCServerFingerprint", hex.EncodeToString(sum[:]))
+			}
+			return nil
+		}
+	}
+	return tlsCfg, nil
+}
+
+// parseFingerprint parses a hex encoded SHA-256 fingerprint, colons are optional
+func parseFingerprint(fp string) ([]byte, error) {
+	pin, err := hex.DecodeString(strings.Replace(strings.TrimSpace(fp), ":", "", -1))
+	if err != nil {
+		return nil, fmt.Errorf("not a hex encoded fingerprint: %v", err)
+	}
+	if len(pin) != sha256.Size {
+		return nil, fmt.Errorf("expected a SHA-256 fingerprint (%d bytes), got %d bytes", sha256.Size, len(pin))
+	}
+	return pin, nil
+}
@@ -0,0 +1,116 @@
+package slirc
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIRCTLSConfig(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	// rejected handshakes are expected
+	srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+	addr := srv.Listener.Addr().String()
+
+	dir, err := ioutil.TempDir("", "slirc-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, caPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(srv.Certificate().Raw)
+	pin := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	dial := func(c *Config) error {
+		tlsCfg, err := c.ircTLSConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn, err := tls.Dial("tcp", addr, tlsCfg)
+		if err == nil {
+			conn.Close()
+		}
+		return err
+	}
+
+	// the test server's certificate is valid for example.com
+	if err := dial(&Config{IRCServer: "example.com:6697", IRCSSL: true}); err == nil {
+		t.Error("untrusted certificate was accepted")
+	}
+	if err := dial(&Config{IRCServer: "example.com:6697", IRCSSL: true, IRCCAFile: caFile, IRCTLSMinVersion: "1.2"}); err != nil {
+		t.Errorf("certificate signed by IRCCAFile was rejected: %v", err)
+	}
+	if err := dial(&Config{IRCServer: "example.com:6697", IRCSSL: true, IRCServerFingerprint: pin}); err != nil {
+		t.Errorf("pinned certificate was rejected: %v", err)
+	}
+	wrongPin := strings.Repeat("AB:", sha256.Size-1) + "AB"
+	if err := dial(&Config{IRCServer: "example.com:6697", IRCSSL: true, IRCCAFile: caFile, IRCServerFingerprint: wrongPin}); err == nil {
+		t.Error("certificate not matching the pin was accepted")
+	}
+}
+
+func TestIRCTLSServerName(t *testing.T) {
+	cases := map[string]string{
+		"irc.example.org":      "irc.example.org",
+		"irc.example.org:6697": "irc.example.org",
+		"[::1]:6697":           "::1",
+		"192.0.2.1:6697":       "192.0.2.1",
+	}
+	for server, want := range cases {
+		tlsCfg, err := (&Config{IRCServer: server}).ircTLSConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tlsCfg.ServerName != want {
+			t.Errorf("ServerName for %q - expected: (%v) - got: (%v)", server, want, tlsCfg.ServerName)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := Config{SlackBotToken: "xoxb-1", SlackChan: "slirctest", IRCServer: "irc.example.org:6697", IRCChan: "#slirctest", IRCNick: "slirc", IRCSSL: true}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("valid config rejected: %v", err)
+	}
+
+	invalid := valid
+	invalid.IRCNick = ""
+	invalid.IRCTLSMinVersion = "1.5"
+	invalid.IRCCAFile = "/does/not/exist.pem"
+	invalid.IRCSASLExternal = true
+	err := invalid.Validate()
+	if err == nil {
+		t.Fatal("invalid config accepted")
+	}
+	for _, want := range []string{"IRCNick is required", "IRCTLSMinVersion", "IRCSASLExternal requires"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+
+	plain := valid
+	plain.IRCSSL = false
+	plain.IRCSASLUser, plain.IRCSASLPassword = "slirc", "hunter2"
+	if err := plain.Validate(); err == nil || !strings.Contains(err.Error(), "IRCSASLUser requires IRCSSL") {
+		t.Errorf("SASL PLAIN without TLS - got: %v", err)
+	}
+
+	if _, err := (&Config{IRCServerFingerprint: "abc"}).ircTLSConfig(); err == nil {
+		t.Error("short fingerprint accepted")
+	}
+}